@@ -0,0 +1,56 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const bandwidthSampleSize = 64 * 1024
+
+// estimateBandwidth returns the bandwidth, in bytes per second, of downloading the
+// first bytes of the file or -1 if it couldn't be measured
+func (f *File) estimateBandwidth(ctx context.Context) int64 {
+
+	size := int64(bandwidthSampleSize)
+	if f.size < size {
+		size = f.size
+	}
+
+	req, err := f.newRequest(ctx, http.MethodGet, f.requestURL())
+	if err != nil {
+		return -1
+	}
+	req.Header.Add("Range", fmt.Sprintf("bytes=0-%d", size-1))
+
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	start := time.Now()
+
+	resp, err := f.do(req)
+	if err != nil {
+		return -1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return -1
+	}
+
+	n, err := io.Copy(ioutil.Discard, resp.Body)
+	if err != nil || n == 0 {
+		return -1
+	}
+
+	elapsed := time.Since(start)
+	if elapsed <= 0 {
+		elapsed = time.Nanosecond
+	}
+
+	return int64(float64(n) / elapsed.Seconds())
+}
@@ -0,0 +1,95 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBandwidthConcurrency(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var ranges int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Header.Get("Range") {
+		case "":
+		case "bytes=0-999":
+			// the bandwidth sample
+			if r.URL.Query().Get("slow") != "" {
+				time.Sleep(100 * time.Millisecond)
+			}
+		default:
+			atomic.AddInt32(&ranges, 1)
+		}
+
+		http.ServeContent(w, r, "bandwidth.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		url      string
+		expected int32
+	}{
+		{url: "/bandwidth.txt", expected: 8},
+		{url: "/bandwidth.txt?slow=1", expected: 2},
+	}
+
+	for _, tt := range tests {
+
+		url := server.URL + tt.url
+		os.RemoveAll((&File{url: url}).resumeDir())
+		atomic.StoreInt32(&ranges, 0)
+
+		var estimate int64
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges: -1,
+			Concurrency: func(size int64) int {
+				return 1
+			},
+			BandwidthConcurrency: func(size int64, estBandwidth int64) int {
+
+				estimate = estBandwidth
+
+				// less than 100KB/s
+				if estBandwidth < 100*1024 {
+					return 2
+				}
+
+				return 8
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		if estimate <= 0 {
+			t.Fatalf("Expected a bandwidth estimate got '%d'", estimate)
+		}
+
+		if n := atomic.LoadInt32(&ranges); n != tt.expected {
+			t.Fatalf("Expected '%d' chunks for bandwidth '%d' got '%d'", tt.expected, estimate, n)
+		}
+	}
+
+	// unmeasurable
+	f := &File{url: server.URL + "/missing", size: 1000, options: new(Options)}
+
+	if estimate := f.estimateBandwidth(context.Background()); estimate != -1 {
+		t.Fatalf("Expected estimate '%d' got '%d'", -1, estimate)
+	}
+}
@@ -1,12 +1,15 @@
 package download
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func BenchmarkDownload(b *testing.B) {
@@ -70,3 +73,59 @@ func BenchmarkDownloadWithRange(b *testing.B) {
 		f.Close()
 	}
 }
+
+func BenchmarkSmallDownloads(b *testing.B) {
+
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "small.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/small.txt"
+	options := &Options{MinSizeForRanges: -1}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+
+		f, err := Open(url, options)
+		if err != nil {
+			b.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1<<20)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "large.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	f, err := Open(server.URL+"/testdata/large.txt", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err = io.Copy(ioutil.Discard, f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package download
+
+import (
+	"io"
+	"sync"
+)
+
+const defaultCopyBufferSize = 32 * 1024
+
+// bufferPools are the copy buffer pools shared across all downloads, one per buffer size
+var bufferPools = struct {
+	sync.Mutex
+	m map[int]*sync.Pool
+}{
+	m: make(map[int]*sync.Pool),
+}
+
+// bufferPool returns the pool of copy buffers of the given size
+func bufferPool(size int) *sync.Pool {
+
+	bufferPools.Lock()
+	defer bufferPools.Unlock()
+
+	if p, ok := bufferPools.m[size]; ok {
+		return p
+	}
+
+	p := &sync.Pool{
+		New: func() interface{} {
+			b := make([]byte, size)
+			return &b
+		},
+	}
+
+	bufferPools.m[size] = p
+
+	return p
+}
+
+// copy copies from src to dst using a pooled buffer
+func (f *File) copy(dst io.Writer, src io.Reader) (int64, error) {
+
+	size := f.options.CopyBufferSize
+	if size <= 0 {
+		size = defaultCopyBufferSize
+	}
+
+	pool := bufferPool(size)
+
+	buf := pool.Get().(*[]byte)
+	defer pool.Put(buf)
+
+	// hide any io.ReaderFrom or io.WriterTo implementations, eg. *os.File's,
+	// so that the pooled buffer is used instead of one being allocated
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+}
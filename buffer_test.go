@@ -0,0 +1,35 @@
+package download
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCopyBuffer(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	for _, size := range []int{0, 7, 4096} {
+
+		f := &File{options: &Options{CopyBufferSize: size}}
+
+		var buf bytes.Buffer
+
+		n, err := f.copy(&buf, bytes.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != int64(len(content)) || !bytes.Equal(buf.Bytes(), content) {
+			t.Fatalf("Copied content does not match for buffer size '%d'", size)
+		}
+	}
+
+	if bufferPool(7) != bufferPool(7) {
+		t.Fatal("Expected the same pool for the same buffer size")
+	}
+
+	if b := bufferPool(7).Get().(*[]byte); len(*b) != 7 {
+		t.Fatalf("Expected pooled buffer size '%d' got '%d'", 7, len(*b))
+	}
+}
@@ -0,0 +1,83 @@
+package download
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Algorithm is the hash algorithm used to verify the Options.Checksum
+type Algorithm int
+
+// Algorithms supported for verifying the Options.Checksum
+const (
+	SHA256 Algorithm = iota
+	SHA1
+	MD5
+)
+
+// String returns the name of the algorithm
+func (a Algorithm) String() string {
+
+	switch a {
+	case SHA1:
+		return "sha1"
+	case MD5:
+		return "md5"
+	default:
+		return "sha256"
+	}
+}
+
+// hash returns a new hash.Hash for the algorithm
+func (a Algorithm) hash() hash.Hash {
+
+	switch a {
+	case SHA1:
+		return sha1.New()
+	case MD5:
+		return md5.New()
+	default:
+		return sha256.New()
+	}
+}
+
+// verifyChecksum compares the checksum of the whole downloaded file against the expected checksum
+func (f *File) verifyChecksum() error {
+
+	got, err := f.checksum(f.options.ChecksumAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(got, f.options.Checksum) {
+		return &ChecksumMismatch{algorithm: f.options.ChecksumAlgorithm, expected: f.options.Checksum, got: got}
+	}
+
+	return nil
+}
+
+// checksum hashes the whole downloaded file, streaming the chunks in order,
+// returning the hex encoded checksum with the read position reset
+func (f *File) checksum(a Algorithm) (string, error) {
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	h := a.hash()
+
+	if _, err := f.copy(h, f); err != nil {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
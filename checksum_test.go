@@ -0,0 +1,160 @@
+package download
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChecksum(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "release.src.tar.gz", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	sha256Sum := sha256.Sum256(content)
+	sha1Sum := sha1.Sum(content)
+	md5Sum := md5.Sum(content)
+
+	tests := []struct {
+		algorithm Algorithm
+		checksum  string
+	}{
+		{algorithm: SHA256, checksum: hex.EncodeToString(sha256Sum[:])},
+		{algorithm: SHA1, checksum: strings.ToUpper(hex.EncodeToString(sha1Sum[:]))},
+		{algorithm: MD5, checksum: hex.EncodeToString(md5Sum[:])},
+	}
+
+	url := server.URL + "/release.src.tar.gz"
+
+	for _, minSize := range []int64{0, -1} {
+
+		for _, tt := range tests {
+
+			os.RemoveAll((&File{url: url}).resumeDir())
+
+			f, err := Open(url, &Options{MinSizeForRanges: minSize, Checksum: tt.checksum, ChecksumAlgorithm: tt.algorithm})
+			if err != nil {
+				t.Fatalf("%s: %s", tt.algorithm, err)
+			}
+
+			// the verification doesn't affect reading
+			b, err := ioutil.ReadAll(f)
+			f.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, content) {
+				t.Fatalf("%s: Downloaded content does not match", tt.algorithm)
+			}
+		}
+
+		var dir string
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges: minSize,
+			Checksum:         tests[2].checksum,
+			TempFileName: func(d string) (string, error) {
+				dir = d
+				return filepath.Join(d, "release.src.tar.gz"), nil
+			},
+		})
+		if err == nil {
+			f.Close()
+			t.Fatal("Expected error got <nil>")
+		}
+
+		if _, ok := err.(*ChecksumMismatch); !ok {
+			t.Fatalf("Expected error to be of type *ChecksumMismatch got '%v'", err)
+		}
+
+		expected := "Invalid sha256 checksum, received '" + tests[0].checksum + "' expected '" + tests[2].checksum + "'"
+		if err.Error() != expected {
+			t.Fatalf("Expected '%s' got '%s'", expected, err)
+		}
+
+		for _, d := range []string{dir, (&File{url: url}).resumeDir()} {
+			if _, err = os.Stat(d); d != "" && !os.IsNotExist(err) {
+				t.Fatalf("Expected the download '%s' to be removed got '%v'", d, err)
+			}
+		}
+	}
+}
+
+func TestFreshOnChecksumFail(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	sum := sha256.Sum256(content)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "fresh.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/fresh.txt"
+	dir := (&File{url: url}).resumeDir()
+	defer os.RemoveAll(dir)
+
+	for _, fresh := range []bool{false, true} {
+
+		// an interrupted download with a complete but corrupt first chunk
+		os.RemoveAll(dir)
+		if err := os.Mkdir(dir, fileMode); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, "0"), bytes.Repeat([]byte("x"), 250), fileMode); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges:    -1,
+			Checksum:            hex.EncodeToString(sum[:]),
+			FreshOnChecksumFail: fresh,
+			Logger:              log.New(ioutil.Discard, "", 0),
+			Concurrency: func(size int64) int {
+				return 4
+			},
+		})
+
+		if !fresh {
+
+			if _, ok := err.(*ChecksumMismatch); !ok {
+				t.Fatalf("Expected error to be of type *ChecksumMismatch got '%v'", err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+	}
+}
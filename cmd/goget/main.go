@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -11,46 +13,78 @@ import (
 	"github.com/vbauerster/mpb"
 )
 
+const stdoutName = "-"
+
 func main() {
 
-	url := os.Args[len(os.Args)-1]
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+
+	flags := flag.NewFlagSet("goget", flag.ContinueOnError)
+	output := flags.String("o", "", "output file name, use '-' to stream the download to stdout")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return errors.New("usage: goget [-o output] url")
+	}
+
+	url := flags.Arg(flags.NArg() - 1)
 
-	progress := mpb.New().SetWidth(80)
-	defer progress.Stop()
+	var options *download.Options
 
-	options := &download.Options{
-		Proxy: func(name string, download int, size int64, r io.Reader) io.Reader {
-			bar := progress.AddBar(size).
-				PrependName(fmt.Sprintf("%s-%d", name, download), 0, 0).
-				PrependCounters("%3s / %3s", mpb.UnitBytes, 18, mpb.DwidthSync|mpb.DextraSpace).
-				AppendPercentage(5, 0)
+	// the progress bars are written to stdout so are only
+	// displayed when not streaming the download there
+	if *output != stdoutName {
 
-			return bar.ProxyReader(r)
-		},
+		progress := mpb.New().SetWidth(80)
+		defer progress.Stop()
+
+		options = &download.Options{
+			Proxy: func(name string, download int, size int64, r io.Reader) io.Reader {
+				bar := progress.AddBar(size).
+					PrependName(fmt.Sprintf("%s-%d", name, download), 0, 0).
+					PrependCounters("%3s / %3s", mpb.UnitBytes, 18, mpb.DwidthSync|mpb.DextraSpace).
+					AppendPercentage(5, 0)
+
+				return bar.ProxyReader(r)
+			},
+		}
 	}
 
 	f, err := download.Open(url, options)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer f.Close()
 
-	info, err := f.Stat()
-	if err != nil {
-		log.Fatal(err)
+	if *output == stdoutName {
+		_, err = io.Copy(stdout, f)
+		return err
 	}
 
-	var output *os.File
-	name := info.Name()
-	output, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
-		log.Fatal(err)
+	name := *output
+	if name == "" {
+
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		name = info.Name()
 	}
-	defer output.Close()
 
-	if _, err = io.Copy(output, f); err != nil {
-		log.Fatal(err)
+	if err = f.Save(name); err != nil {
+		return err
 	}
 
 	log.Printf("Success. %s saved.", name)
+
+	return nil
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStdout(t *testing.T) {
+
+	content := []byte("streamed to stdout..")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/data.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "data.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var stdout bytes.Buffer
+
+	err := run([]string{"-o", "-", server.URL + "/testdata/data.txt"}, &stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(stdout.Bytes(), content) {
+		t.Fatalf("Expected '%s' got '%s'", content, stdout.Bytes())
+	}
+}
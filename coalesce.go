@@ -0,0 +1,56 @@
+package download
+
+import (
+	"io"
+	"os"
+)
+
+// coalesce concatenates the downloaded chunk files, in order, into a single file in the download
+// directory and removes them, so that the File is backed by one file. In memory chunks are left,
+// as are the ranges of OpenRanges which must keep their original offsets.
+func (f *File) coalesce() error {
+
+	if len(f.readers) < 2 || f.sparse {
+		return nil
+	}
+
+	for i := 0; i < len(f.readers); i++ {
+		if _, ok := f.readers[i].(namer); !ok {
+			return nil
+		}
+	}
+
+	fh, err := f.createTempFile()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+
+	for i := 0; i < len(f.readers) && err == nil; i++ {
+
+		var n int64
+
+		if _, err = f.readers[i].Seek(0, io.SeekStart); err == nil {
+			n, err = f.copy(fh, f.readers[i])
+			size += n
+		}
+	}
+
+	if err != nil {
+		fh.Close()
+		os.Remove(fh.Name())
+		return err
+	}
+
+	for i := 0; i < len(f.readers); i++ {
+		f.readers[i].Close()
+		os.Remove(f.readers[i].(namer).Name())
+	}
+
+	f.readers = []chunkReader{fh}
+	f.chunks = []chunk{{start: 0, end: size - 1}}
+
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
@@ -0,0 +1,70 @@
+package download
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCoalesce(t *testing.T) {
+
+	content := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "coalesced.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/coalesced.bin"
+
+	f, err := Open(url, &Options{MinSizeForRanges: -1, Coalesce: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	files := f.TempFiles()
+	if len(files) != 1 {
+		t.Fatalf("Expected a single file got '%d'", len(files))
+	}
+
+	infos, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fi := range infos {
+		if fi.Name() != resumeMetadataName && filepath.Join(f.dir, fi.Name()) != files[0] {
+			t.Fatalf("Expected the chunk files to be removed got '%s'", fi.Name())
+		}
+	}
+
+	b, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Coalesced file does not match")
+	}
+
+	if _, err = f.Seek(int64(len(content)/2), io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content[len(content)/2:]) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
@@ -0,0 +1,36 @@
+package download
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+)
+
+// decompress returns a reader decompressing r according to the Content-Encoding, gzip or
+// deflate, and whether it does; other encodings are returned as they are.
+func decompress(encoding string, r io.Reader) (io.Reader, bool, error) {
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return gz, true, nil
+
+	case "deflate":
+
+		// the http deflate encoding is zlib wrapped
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return zr, true, nil
+	}
+
+	return r, false, nil
+}
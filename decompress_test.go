@@ -0,0 +1,113 @@
+package download
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDecompressResponse(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	var gzipped, deflated bytes.Buffer
+
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(content)
+	gz.Close()
+
+	zw := zlib.NewWriter(&deflated)
+	zw.Write(content)
+	zw.Close()
+
+	var ranged int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranged, 1)
+		}
+
+		var b []byte
+
+		switch r.URL.Path {
+		case "/testdata/gzip.txt":
+			w.Header().Set("Content-Encoding", "gzip")
+			b = gzipped.Bytes()
+		case "/testdata/deflate.txt":
+			w.Header().Set("Content-Encoding", "deflate")
+			b = deflated.Bytes()
+		default:
+			b = content
+		}
+
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+
+		if r.Method != http.MethodHead {
+			w.Write(b)
+		}
+	}))
+	defer server.Close()
+
+	options := &Options{
+		MinSizeForRanges:   -1,
+		DecompressResponse: true,
+		Headers:            http.Header{"Accept-Encoding": {"gzip, deflate"}}, // stops the transport decompressing
+	}
+
+	for _, name := range []string{"gzip.txt", "deflate.txt", "identity.txt"} {
+
+		f, err := Open(server.URL+"/testdata/"+name, options)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fi.Size() != int64(len(content)) {
+			t.Fatalf("%s: Expected size '%d' got '%d'", name, len(content), fi.Size())
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%s: Downloaded content does not match", name)
+		}
+	}
+
+	if n := atomic.LoadInt32(&ranged); n != 0 {
+		t.Fatalf("Expected no ranged requests got '%d'", n)
+	}
+
+	// the compressed content is kept as is by default
+	f, err := Open(server.URL+"/testdata/gzip.txt", &Options{Headers: options.Headers})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, gzipped.Bytes()) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
@@ -0,0 +1,48 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// trailerDigest returns the hex encoded sha256 digest of the content from the Content-Digest
+// or Digest trailer, eg. "sha-256=:X48E9q...=:" or "sha-256=X48E9q...=", if any
+func trailerDigest(h http.Header) string {
+
+	for _, v := range []string{h.Get("Content-Digest"), h.Get("Digest")} {
+		for _, d := range strings.Split(v, ",") {
+
+			kv := strings.SplitN(strings.TrimSpace(d), "=", 2)
+			if len(kv) != 2 || !strings.EqualFold(kv[0], "sha-256") {
+				continue
+			}
+
+			b, err := base64.StdEncoding.DecodeString(strings.Trim(kv[1], ":"))
+			if err == nil && len(b) == sha256.Size {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+
+	return ""
+}
+
+// verifyDigest compares the sha256 checksum of the whole downloaded file against the digest
+// learned from a trailer, discarding the stored digest on mismatch as it may be stale
+func (f *File) verifyDigest() error {
+
+	got, err := f.checksum(SHA256)
+	if err != nil {
+		return err
+	}
+
+	if got != f.digest {
+		f.removeResume()
+		return &ChecksumMismatch{algorithm: SHA256, expected: f.digest, got: got}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package download
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestTrailerDigest(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	corrupt := append([]byte("X"), content[1:]...)
+
+	sum := sha256.Sum256(content)
+
+	body, trailer := content, true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		if trailer {
+			w.Header().Set("Trailer", "Digest")
+		}
+
+		w.Write(body)
+
+		if trailer {
+			w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		}
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/digest.txt"
+	dir := (&File{url: url, options: new(Options)}).resumeDir()
+
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	f, err := Open(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	meta, ok := (&File{url: url, options: new(Options)}).readResumeMetadata()
+	if !ok || meta.Digest != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Expected the trailer digest to be stored got '%s'", meta.Digest)
+	}
+
+	// the next download is verified against the stored digest without a trailer
+	trailer = false
+
+	f, err = Open(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	body = corrupt
+
+	_, err = Open(url, nil)
+	if _, ok := err.(*ChecksumMismatch); !ok {
+		t.Fatalf("Expected error to be of type *ChecksumMismatch got '%v'", err)
+	}
+
+	// the possibly stale digest is discarded
+	if _, err = os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("Expected the resume directory to be removed got '%v'", err)
+	}
+}
+
+func TestParseTrailerDigest(t *testing.T) {
+
+	sum := sha256.Sum256([]byte("content"))
+	b64 := base64.StdEncoding.EncodeToString(sum[:])
+	expected := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		header   http.Header
+		expected string
+	}{
+		{header: http.Header{"Digest": {"sha-256=" + b64}}, expected: expected},
+		{header: http.Header{"Digest": {"md5=HUXZLQLMuI/KZ5KDcJPcOA==, SHA-256=" + b64}}, expected: expected},
+		{header: http.Header{"Content-Digest": {"sha-256=:" + b64 + ":"}}, expected: expected},
+		{header: http.Header{"Digest": {"md5=HUXZLQLMuI/KZ5KDcJPcOA=="}}},
+		{header: http.Header{"Digest": {"sha-256=invalid"}}},
+		{header: http.Header{}},
+	}
+
+	for i, tt := range tests {
+		if got := trailerDigest(tt.header); got != tt.expected {
+			t.Fatalf("%d: Expected '%s' got '%s'", i, tt.expected, got)
+		}
+	}
+}
@@ -2,6 +2,8 @@
 Package download provides a library for interruptable, resumable download
 acceleration with automatic Accept-Ranges support.
 
+ftp:// urls are also supported, downloaded in a single stream and resumed using REST.
+
 see all examples https://github.com/joeybloggs/go-download/tree/master/examples
 
 	package main
@@ -1,29 +1,43 @@
 package download
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha1"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	defaultGoroutines = 10
 	defaultDir        = "go-download"
+	partialName       = "partial"
+
+	defaultMinSizeForRanges = 1 << 20 // 1MB
+	defaultRetryBackoff     = 100 * time.Millisecond
 )
 
 var (
-	_           io.Reader = (*File)(nil)
-	fileMode              = os.FileMode(0770)
-	defaultTime           = time.Time{}
+	_           io.Reader   = (*File)(nil)
+	_           io.ReaderAt = (*File)(nil)
+	_           io.Seeker   = (*File)(nil)
+	_           io.WriterTo = (*File)(nil)
+	fileMode                = os.FileMode(0770)
+	defaultTime             = time.Time{}
 )
 
 // Options contains any specific configuration values
@@ -33,6 +47,323 @@ type Options struct {
 	Proxy       ProxyFn
 	Client      ClientFn
 	Request     RequestFn
+
+	// IdleConnTimeout is the maximum amount of time an idle keep-alive
+	// connection will remain idle before closing itself, zero uses the
+	// http.DefaultTransport value.
+	//
+	// Ignored when Client is set, configure the Client's Transport instead.
+	IdleConnTimeout time.Duration
+
+	// DisableKeepAlives prevents connections being reused between requests.
+	//
+	// Ignored when Client is set, configure the Client's Transport instead.
+	DisableKeepAlives bool
+
+	// ReverseOrder schedules the ranged chunk downloads starting with the last chunk,
+	// useful for formats such as zip which have their index at the end of the file.
+	// The downloaded file is still assembled in the correct order.
+	ReverseOrder bool
+
+	// MinSizeForRanges is the minimum file size for which a ranged download is used,
+	// below it the file is always downloaded in a single stream as the overhead of the
+	// additional requests outweighs the benefit. Default is 1MB, a negative value
+	// allows ranged downloads of any size.
+	MinSizeForRanges int64
+
+	// QueryModifier allows the url query of each ranged partial request to be modified
+	QueryModifier QueryModifierFn
+
+	// MagicBytes when set must match the first bytes of the downloaded file
+	// otherwise a *MagicMismatch error is returned, eg. to detect an html error
+	// page served instead of the expected file.
+	MagicBytes []byte
+
+	// IgnoreHeadLength ignores the Content-Length of the HEAD request, for servers that
+	// report it incorrectly, and instead uses the total of the Content-Range returned
+	// when requesting the first byte of a ranged download.
+	IgnoreHeadLength bool
+
+	// ChunkLaunchRate limits the number of ranged chunk downloads started per second,
+	// staggering the connections to avoid tripping server rate limiters.
+	// Zero starts all of the chunk downloads at once.
+	ChunkLaunchRate float64
+
+	// MaxParallel limits the number of ranged chunk downloads in flight at once, the remaining
+	// chunks wait for one to finish, eg. to slice a file finely for resuming without opening a
+	// connection per chunk. Zero downloads all of the chunks at once.
+	MaxParallel int
+
+	// MaxElapsed limits the time taken by the whole download, including all of the retries and
+	// their backoffs, returning a *DeadlineExceeded error wrapping the last error once exceeded.
+	// It's simpler than a context deadline for giving up after a while. Zero is no limit.
+	MaxElapsed time.Duration
+
+	// DrainOnError lets the remaining ranged chunk downloads run to completion when one of
+	// them fails, by default they are cancelled as soon as the first failure occurs.
+	DrainOnError bool
+
+	// ExponentialChunks partitions ranged downloads into chunks that roughly double in size
+	// rather than equal ones, the smaller first chunks completing sooner for consumers
+	// that want the start of the file as soon as possible.
+	ExponentialChunks bool
+
+	// OnFallback is called with the reason whenever the file is downloaded
+	// in a single stream rather than using a ranged download.
+	OnFallback FallbackFn
+
+	// FallbackOnIgnoredRange downloads the file in a single stream when the server advertises
+	// range support but answers the ranged requests with the whole file, by default
+	// a *RangeNotSupported error is returned so the caller can decide how to retry.
+	FallbackOnIgnoredRange bool
+
+	// VerifyOnClose makes Close return a *ShortDownload error when the size of the downloaded
+	// file(s) doesn't match the expected size, by default only a warning is logged.
+	VerifyOnClose bool
+
+	// BaseURL is resolved against relative urls passed to Open, eg. to switch between
+	// environments without rewriting the urls, absolute urls are used as is. Resolution
+	// follows RFC 3986 so the BaseURL should end with a '/' to keep it's last path segment.
+	BaseURL string
+
+	// Audit is called with a RequestRecord for every http request made, including the
+	// HEAD request, eg. to keep an audit log. It is called concurrently by the partial
+	// downloads so must be safe for concurrent use.
+	Audit AuditFn
+
+	// MaxSizeHeader is the name of an http response header, eg. "X-Max-Size", declaring the
+	// maximum size in bytes of the file. When set and present in the response a
+	// *MaxSizeExceeded error is returned if the content length is larger.
+	MaxSizeHeader string
+
+	// Tracer, when set, is used to start a span around the HEAD request and each chunk
+	// download, eg. to see the per chunk latency. It must be safe for concurrent use.
+	Tracer Tracer
+
+	// CopyBufferSize is the size of the buffers used to write the downloaded content to disk,
+	// the buffers are pooled and shared across all downloads. Default is 32KB.
+	CopyBufferSize int
+
+	// RangeDecision, when set, decides whether to use a ranged download when the server
+	// supports it, eg. a single stream can be faster over high latency links. It is passed
+	// the size and the round trip time of the HEAD request as an estimate of the latency.
+	RangeDecision RangeDecisionFn
+
+	// Progress, when set, is called as the download progresses with the number of bytes
+	// read so far out of the size of each chunk, including those already downloaded
+	// when resuming. It may be called concurrently for different chunks.
+	Progress ProgressFn
+
+	// AggregateProgress makes Progress report the sum of all chunks against the total
+	// size as a single download with index 0, eg. to draw one combined progress bar.
+	AggregateProgress bool
+
+	// ProgressInterval is the minimum interval between Progress calls, the final call once
+	// complete is always made. Default is 100ms, a negative value reports every read.
+	ProgressInterval time.Duration
+
+	// NoResume ignores and removes any previously interrupted download of the
+	// same url so that the file is always downloaded from scratch.
+	NoResume bool
+
+	// KeepOnFailure keeps the downloaded chunks of a failed ranged download in the TempDir
+	// for the next download of the url to resume, by default they're removed.
+	KeepOnFailure bool
+
+	// MaxConnsHeader is the name of an http response header, eg. "X-Max-Connections",
+	// advertising the maximum number of concurrent connections the server allows. When
+	// set and present in the HEAD response it caps the number of chunks downloaded.
+	MaxConnsHeader string
+
+	// TempFileName, when set, returns the path of the file to create within dir for a
+	// single stream download, eg. for predictable names. Default is a random name.
+	TempFileName TempFileNameFn
+
+	// ConnMaxLifetime, when > 0, is the interval at which connections are rotated so that
+	// a new request, eg. a chunk, gets a fresh connection. Connections in use when due
+	// are not interrupted but closed once idle so may live up to about twice as long.
+	ConnMaxLifetime time.Duration
+
+	// MaxRetries is the number of times a failed chunk download is retried, requesting only
+	// the bytes not yet downloaded, before the download fails. Default is 0, no retries.
+	MaxRetries int
+
+	// RetryBackoff returns how long to wait before the given retry attempt, starting at 1.
+	// Default doubles from 100ms for each attempt.
+	RetryBackoff BackoffFn
+
+	// MinBackoff and MaxBackoff, when > 0, clamp the RetryBackoff between them, eg. to avoid
+	// both hammering the server and multi-minute stalls.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Verify, when set, is called once downloaded with a seekable view of the content for
+	// custom verification, eg. checking a file header's version. A returned error fails Open.
+	Verify VerifyFn
+
+	// Checksum is the expected hex encoded checksum of the file, when set Open returns a
+	// *ChecksumMismatch error and removes the download if it doesn't match.
+	Checksum string
+
+	// ChecksumAlgorithm is the hash algorithm of the Checksum. Default is SHA256.
+	ChecksumAlgorithm Algorithm
+
+	// FreshOnChecksumFail downloads the file again from scratch, once, when a resumed download
+	// fails the Checksum verification, eg. as the interrupted download's chunks were corrupt.
+	FreshOnChecksumFail bool
+
+	// Suffix, when > 0, downloads only the last Suffix bytes of the file using a single
+	// suffix range request without a HEAD request, eg. to read the trailer of a huge file.
+	// Only http and https urls support it.
+	// See File.ContentRange for the offset and total size of the file.
+	Suffix int64
+
+	// Headers are added to every http request made, eg. Authorization or User-Agent. Any
+	// Range header is ignored, headers set by the Request function take precedence.
+	Headers http.Header
+
+	// InMemory keeps the downloaded file(s) in memory rather than in temporary files,
+	// eg. for many small downloads. In memory downloads can't be resumed.
+	InMemory bool
+
+	// Coalesce concatenates the chunks of a ranged download into a single temporary file once
+	// downloaded, removing the per chunk files, eg. to pass the TempFiles name to other programs.
+	// The ranges downloaded by OpenRanges aren't coalesced.
+	Coalesce bool
+
+	// Mmap maps the downloaded file(s) into memory once downloaded, eg. for large files read
+	// repeatedly at random offsets, so ReadAt is served from the mapping. Close unmaps them.
+	Mmap bool
+
+	// MinResumePercent is the minimum percentage, 0-100, of an interrupted download that must
+	// have been downloaded for it to be resumed, otherwise it's discarded and started over.
+	MinResumePercent float64
+
+	// BandwidthConcurrency, when set, supersedes Concurrency and is passed an estimate of
+	// the bandwidth, in bytes per second, measured by timing the download of the first
+	// bytes of the file. The estimate is -1 if it couldn't be measured.
+	BandwidthConcurrency BandwidthConcurrencyFn
+
+	// PieceHashes are the SHA-1 hashes of each PieceLength piece of the file, BitTorrent
+	// style, verified once downloaded. A corrupt piece is downloaded again, only it's
+	// byte range, before failing with a *PieceMismatch error.
+	PieceHashes [][]byte
+
+	// PieceLength is the length in bytes of each piece of PieceHashes, the last piece
+	// may be shorter.
+	PieceLength int64
+
+	// TempDir is the existing directory the downloaded file(s), and any interrupted downloads
+	// kept for resuming, are stored in, eg. a larger volume than a tmpfs /tmp.
+	// Default is os.TempDir().
+	TempDir string
+
+	// Preflight sends an OPTIONS request before the HEAD request, for APIs requiring one, whose
+	// Allow and Accept-Ranges headers are used as hints. The file is downloaded in a single stream
+	// when they disallow HEAD requests or ranges, ranges allowed by them don't need advertising by the HEAD.
+	Preflight bool
+
+	// LocalAddr is the local address the connections are made from, eg. to egress a specific
+	// interface of a multi-homed host, normally a *net.TCPAddr with a zero port.
+	LocalAddr net.Addr
+
+	// MaxBytesPerSecond, when > 0, limits the download rate to the given number of bytes per
+	// second shared by all the chunks, eg. for background downloads not saturating the link.
+	MaxBytesPerSecond int64
+
+	// ValidateJSON checks that the downloaded file is a single well-formed JSON value, streaming
+	// it, returning an *InvalidContent error if not, eg. for a truncated or error page response.
+	ValidateJSON bool
+
+	// Accept, when set, is called once downloaded and verified as the final decision whether to
+	// keep the download, eg. rejecting a too old embedded version. Returning false fails Open with
+	// a *Rejected error, returning an error fails Open with it, the download being removed.
+	Accept AcceptFn
+
+	// Provenance, when set, is the expected size and SHA-256 digest of the file, both verified once
+	// downloaded, Open returning a *ProvenanceMismatch error naming the failed check otherwise.
+	Provenance *Provenance
+
+	// WriteChecksumFile makes Save also write a path + ".sha256" file containing the SHA-256
+	// digest of the saved file in the "<hex>  <name>" format of sha256sum.
+	WriteChecksumFile bool
+
+	// GzipLevel is the compression level used by SaveGzip, eg. gzip.BestSpeed. Zero, which is
+	// also gzip.NoCompression, uses gzip.DefaultCompression.
+	GzipLevel int
+
+	// DecompressResponse decompresses a gzip or deflate Content-Encoding of the response that
+	// the transport left compressed, eg. when Headers sets Accept-Encoding. A compressed stream
+	// can't be ranged so the file is always downloaded in a single stream.
+	DecompressResponse bool
+
+	// OnChunkFile is called with the path of each ranged chunk file once it's fully written and
+	// closed, before the File uses it, eg. to replicate the chunks elsewhere. It's called from
+	// the chunk's goroutine so may be called concurrently. Not called for InMemory downloads.
+	OnChunkFile ChunkFileFn
+
+	// ZeroFillGaps makes ReadAt read the gaps between the ranges downloaded by OpenRanges as
+	// zeros rather than returning a *RangeNotDownloaded error.
+	ZeroFillGaps bool
+
+	// StateStore persists the resume metadata of interrupted downloads rather than the default
+	// metadata file alongside the downloaded chunks, eg. backed by bolt for applications managing
+	// many downloads. The chunks themselves are still kept in the TempDir.
+	StateStore StateStore
+
+	// Logger receives the notices and warnings otherwise written to the standard logger along
+	// with debug events, eg. the HEAD result, range decision, chunks starting and finishing,
+	// retries and resumes, to make failing downloads visible. A *log.Logger satisfies it.
+	Logger Logger
+
+	// UpgradeToHTTPS downloads an http:// url over https when a HEAD request of the https url
+	// succeeds, falling back to http otherwise, eg. for servers that may not support TLS.
+	UpgradeToHTTPS bool
+}
+
+func (o *Options) retryBackoff(attempt int) time.Duration {
+
+	backoff := defaultBackoffFn(attempt)
+
+	if o.RetryBackoff != nil {
+		backoff = o.RetryBackoff(attempt)
+	}
+
+	switch {
+	case o.MinBackoff > 0 && backoff < o.MinBackoff:
+		return o.MinBackoff
+	case o.MaxBackoff > 0 && backoff > o.MaxBackoff:
+		return o.MaxBackoff
+	}
+
+	return backoff
+}
+
+func (o *Options) progressInterval() time.Duration {
+
+	if o.ProgressInterval == 0 {
+		return defaultProgressInterval
+	}
+
+	return o.ProgressInterval
+}
+
+func (o *Options) tempDir() string {
+
+	if o == nil || o.TempDir == "" {
+		return os.TempDir()
+	}
+
+	return o.TempDir
+}
+
+func (o *Options) minSizeForRanges() int64 {
+
+	if o.MinSizeForRanges == 0 {
+		return defaultMinSizeForRanges
+	}
+
+	return o.MinSizeForRanges
 }
 
 // RequestFn allows for additional information, such as http headers, to the http request
@@ -40,6 +371,49 @@ type Options struct {
 // Do not alter the "Range" http headers or the download can become corrupt
 type RequestFn func(r *http.Request)
 
+// QueryModifierFn returns the url query values to use for the partial download of
+// the inclusive byte range start-end, eg. adding a range specific signature.
+type QueryModifierFn func(q url.Values, idx int, start, end int64) url.Values
+
+// FallbackFn is the function called with a description of why
+// a single stream download was used instead of a ranged one
+type FallbackFn func(reason string)
+
+// RangeDecisionFn returns whether to use a ranged download for the
+// file of the given size with the estimated round trip time
+type RangeDecisionFn func(size int64, rttEstimate time.Duration) bool
+
+// ChunkFileFn is called with the index and path of a chunk file once it's complete
+type ChunkFileFn func(idx int, path string)
+
+// ProgressFn is the function called with the number of bytes read of the
+// download, chunk or aggregated, of the given size; size is -1 if unknown
+type ProgressFn func(download int, read, size int64)
+
+// TempFileNameFn returns the path of the file to create in dir
+type TempFileNameFn func(dir string) (string, error)
+
+// VerifyFn verifies the downloaded content, returning an error if it's invalid
+type VerifyFn func(r io.ReadSeeker) error
+
+// AcceptFn returns if the downloaded file, described by info, should be kept
+type AcceptFn func(info os.FileInfo, r io.ReadSeeker) (bool, error)
+
+// BackoffFn returns the duration to wait before the retry attempt
+type BackoffFn func(attempt int) time.Duration
+
+// AuditFn is the function called with the record of each http request made
+type AuditFn func(r RequestRecord)
+
+// RequestRecord describes an http request made for the download, Status is 0 when
+// no response was received.
+type RequestRecord struct {
+	Method string
+	URL    string
+	Range  string
+	Status int
+}
+
 // ClientFn allows for a custom http.Client to be used for the http request
 type ClientFn func() http.Client
 
@@ -49,25 +423,71 @@ type ClientFn func() http.Client
 // if returned value is < 1 then the default value will be used
 type ConcurrencyFn func(size int64) int
 
+// BandwidthConcurrencyFn is the function used to determine the level of concurrency from
+// the size and the estimated bandwidth in bytes per second.
+//
+// if returned value is < 1 then the default value will be used
+type BandwidthConcurrencyFn func(size int64, estBandwidth int64) int
+
 // ProxyFn is the function used to pass the download io.Reader for proxying.
 // eg. displaying a progress bar of the download.
 type ProxyFn func(name string, download int, size int64, r io.Reader) io.Reader
 
 // File represents an open file descriptor to a downloaded file(s)
 type File struct {
-	url      string
-	dir      string
-	baseName string
-	size     int64
-	modTime  time.Time
-	options  *Options
-	readers  []io.ReadCloser
+	url          string
+	dir          string
+	baseName     string
+	size         int64
+	modTime      time.Time
+	mimeType     string
+	options      *Options
+	readers      []chunkReader
+	chunks       []chunk
+	pos          int64
+	aggregate    *aggregate
+	maxConns     int
+	offset       int64
+	total        int64
+	lazy         *lazyLoad
+	head         *http.Response
+	headRTT      time.Duration
+	etag         string
+	lastModified string
+	limiter      *rateLimiter
+	finalURL     string
+	template     *http.Request
+	mirrors      []string
+	digest       string
+	sparse       bool
+	started      time.Time
+	stats        DownloadStats
+	resumed      int64
+	retries      int64
 	io.Reader
 }
 
+// chunk is the inclusive byte range of a partial download
+type chunk struct {
+	start int64
+	end   int64
+}
+
+// chunkReader is the downloaded content of a chunk
+type chunkReader interface {
+	io.ReadCloser
+	io.Seeker
+	io.ReaderAt
+}
+
+// namer is a chunkReader backed by a named file
+type namer interface {
+	Name() string
+}
+
 type partialResult struct {
 	idx int
-	r   io.ReadCloser
+	r   chunkReader
 	err error
 }
 
@@ -82,346 +502,2179 @@ func Open(url string, options *Options) (*File, error) {
 
 // OpenContext downloads and opens the file(s) downloaded by the given url and is cancellable using the provided context.
 // The context provided must be non-nil
+//
+// When a ranged download is cancelled, or times out, the partially downloaded File is returned along with the
+// *Canceled or *DeadlineExceeded error for inspection using Progress and MissingRanges, it's content is incomplete.
+// GracefulClose keeps the downloaded chunks for resuming, Close discards them.
+//
+// When a ranged download fails the downloaded chunks are removed, unless Options.KeepOnFailure is set
+// for the next download of the url to resume them.
 func OpenContext(ctx context.Context, url string, options *Options) (*File, error) {
 
 	if ctx == nil {
 		panic("nil context")
 	}
 
-	f := &File{
-		url:      url,
-		baseName: filepath.Base(url),
-		options:  options,
-	}
-
-	req, err := http.NewRequest(http.MethodHead, f.url, nil)
+	f, err := newFile(url, options)
 	if err != nil {
 		return nil, err
 	}
-	req = req.WithContext(ctx)
-	if f.options != nil && f.options.Request != nil {
-		f.options.Request(req)
-	}
 
-	var client http.Client
-	if f.options != nil && f.options.Client != nil {
-		client = f.options.Client()
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
+	return f.opened(ctx, f.within(ctx, f.open))
+}
 
-	if resp.StatusCode != http.StatusOK {
-		// not all services support HEAD requests
-		// so if this fails just move along to the
-		// GET portion, with a warning
-		log.Printf("notice: unexpected HEAD response code '%d', proceeding with download.\n", resp.StatusCode)
-		err = f.download(ctx)
-	} else {
-		f.size = resp.ContentLength
+// OpenRequest downloads and opens the file(s) downloaded by the given request and is cancellable using the
+// provided context. The request is used as a template, cloned for each request made with the method and
+// Range header swapped, eg. for signed requests; it's body is ignored. The context provided must be non-nil
+func OpenRequest(ctx context.Context, req *http.Request, options *Options) (*File, error) {
 
-		if t := resp.Header.Get("Accept-Ranges"); t == "bytes" {
-			err = f.downloadRangeBytes(ctx)
-		} else {
-			err = f.download(ctx)
-		}
+	if ctx == nil {
+		panic("nil context")
 	}
 
+	f, err := newFile(req.URL.String(), options)
 	if err != nil {
-		f.closeFileHandles()
 		return nil, err
 	}
 
-	return f, nil
+	f.template = req
+
+	return f.opened(ctx, f.within(ctx, f.open))
 }
 
-func (f *File) download(ctx context.Context) error {
+// protocols are the download implementations of the url schemes other than http and https
+var protocols = map[string]func(f *File, ctx context.Context) error{
+	"ftp": (*File).downloadFTP,
+}
 
-	req, err := http.NewRequest(http.MethodGet, f.url, nil)
-	if err != nil {
-		return err
-	}
-	req = req.WithContext(ctx)
+// protocol returns the download implementation of the url's scheme when not http or https
+func (f *File) protocol() (func(f *File, ctx context.Context) error, bool) {
 
-	if f.options != nil && f.options.Request != nil {
-		f.options.Request(req)
+	u, err := url.Parse(f.url)
+	if err != nil {
+		return nil, false
 	}
 
-	var client http.Client
+	download, ok := protocols[u.Scheme]
+	return download, ok
+}
 
-	if f.options != nil && f.options.Client != nil {
-		client = f.options.Client()
-	}
+// httpOnly returns an *UnsupportedProtocol error when the url is downloaded by one of the
+// protocols, for the features implemented only over http
+func httpOnly(rawurl, feature string) error {
 
-	resp, err := client.Do(req)
+	u, err := url.Parse(rawurl)
 	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return &InvalidResponseCode{got: resp.StatusCode, expected: http.StatusOK}
+		return nil
 	}
 
-	f.dir, err = ioutil.TempDir("", defaultDir)
-	if err != nil {
-		return err
+	if _, ok := protocols[u.Scheme]; ok {
+		return &UnsupportedProtocol{scheme: u.Scheme, feature: feature}
 	}
 
-	fh, err := ioutil.TempFile(f.dir, "")
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	f.readers = make([]io.ReadCloser, 1)
-	f.readers[0] = fh
+// open downloads the file(s), using ranges when the HEAD response allows
+func (f *File) open(ctx context.Context) error {
 
-	var read io.Reader = resp.Body
+	if download, ok := f.protocol(); ok {
+		return download(f, ctx)
+	}
 
-	if f.options != nil && f.options.Proxy != nil {
-		read = f.options.Proxy(f.baseName, 0, f.size, read)
+	// OpenLazy has already tried upgrading
+	if f.options.UpgradeToHTTPS && f.head == nil {
+		f.upgradeToHTTPS(ctx)
 	}
 
-	_, err = io.Copy(fh, read)
-	if err != nil {
-		return err
+	if f.options.Suffix > 0 {
+		return f.downloadSuffix(ctx)
 	}
 
-	fh.Seek(0, 0)
+	var hints http.Header
 
-	f.Reader = fh
-	f.modTime = time.Now()
+	if f.options.Preflight {
 
-	return nil
-}
+		var err error
 
-func (f *File) downloadRangeBytes(ctx context.Context) (err error) {
+		if hints, err = f.preflight(ctx); err != nil {
+			return err
+		}
 
-	if f.size <= 0 {
-		return fmt.Errorf("Invalid content length '%d'", f.size)
+		switch {
+		case len(hints.Values("Accept-Ranges")) > 0 && !acceptsRanges(hints):
+			return f.fallback(ctx, false, "preflight does not allow ranges")
+		case hints.Get("Allow") != "" && !allowsMethod(hints, http.MethodHead):
+			return f.fallback(ctx, false, "preflight does not allow HEAD")
+		}
 	}
 
-	var resume bool
+	// the HEAD request of OpenLazy is reused
+	resp, rtt := f.head, f.headRTT
+	f.head = nil
 
-	f.dir = filepath.Join(os.TempDir(), defaultDir+f.generateHash())
+	if resp == nil {
 
-	if _, err = os.Stat(f.dir); os.IsNotExist(err) {
-		err = os.Mkdir(f.dir, fileMode) // only owner and group have RWX access
-		if err != nil {
-			return
+		var err error
+
+		if resp, rtt, err = f.headRequest(ctx); err != nil {
+			return err
 		}
-	} else {
-		resume = true
 	}
 
-	var goroutines int
+	var err error
 
-	if f.options == nil || f.options.Concurrency == nil {
-		goroutines = defaultConcurrencyFn(f.size)
+	if resp.StatusCode != http.StatusOK {
+		// not all services support HEAD requests
+		// so if this fails just move along to the
+		// GET portion, with a warning
+		f.logf("notice: unexpected HEAD response code '%d', proceeding with download.\n", resp.StatusCode)
+		err = f.fallback(ctx, false, fmt.Sprintf("unexpected HEAD response code '%d'", resp.StatusCode))
 	} else {
-		goroutines = f.options.Concurrency(f.size)
-		if goroutines < 1 {
-			goroutines = defaultConcurrencyFn(f.size)
-		}
-	}
+		f.size = resp.ContentLength
+		f.mimeType = resp.Header.Get("Content-Type")
+		f.etag = resp.Header.Get("ETag")
+		f.lastModified = resp.Header.Get("Last-Modified")
 
-	chunkSize := f.size / int64(goroutines)
-	remainer := f.size % chunkSize
-	var pos int64
+		if name := dispositionName(resp.Header); name != "" {
+			f.baseName = name
+		}
 
-	chunkSize--
+		// the chunks of an interrupted download of a since changed file are stale
+		if f.resumeChanged() {
+			f.debugf("file changed since the interrupted download, starting over\n")
+			f.removeResume()
+		} else if meta, ok := f.readResumeMetadata(); ok {
+			f.digest = meta.Digest
+		}
 
-	f.readers = make([]io.ReadCloser, goroutines, goroutines)
+		rangeable := acceptsRanges(resp.Header) || acceptsRanges(hints)
 
-	ch := make(chan partialResult)
+		f.debugf("HEAD response size '%d' rangeable '%t' etag '%s'\n", f.size, rangeable, f.etag)
 
-	var i int
+		if f.options.MaxConnsHeader != "" {
+			if v := resp.Header.Get(f.options.MaxConnsHeader); v != "" {
+				if f.maxConns, err = strconv.Atoi(v); err != nil {
+					f.logf("notice: invalid '%s' header value '%s', ignoring.\n", f.options.MaxConnsHeader, v)
+					err = nil
+				}
+			}
+		}
 
-	for ; i < goroutines; i++ {
+		switch {
+		case rangeable && f.options.IgnoreHeadLength:
+			if f.size, err = f.probeSize(ctx); err != nil {
+				return err
+			}
+		case rangeable && f.size < 0:
+			// the HEAD omitted the length, eg. chunked responses,
+			// but it may be available from a ranged request
+			if size, err := f.probeSize(ctx); err == nil {
+				f.size = size
+			}
+		}
 
-		if i == goroutines-1 {
-			chunkSize += remainer // add remainer to last download
+		if rangeable && !f.options.NoResume {
+			if stored, ok := f.storedSize(); ok && stored != f.size {
+				// an interrupted download's stored size wins over a possibly
+				// wrong HEAD length unless a ranged request disagrees with it
+				if size, err := f.probeSize(ctx); err == nil && size != stored {
+					f.size = size
+					f.removeResume()
+				} else {
+					f.size = stored
+				}
+			}
 		}
 
-		go f.downloadPartial(ctx, resume, i, pos, pos+chunkSize, ch)
+		if err = f.checkMaxSize(resp.Header, f.size); err != nil {
+			return err
+		}
 
-		pos += chunkSize + 1
+		switch {
+		case f.options.DecompressResponse:
+			err = f.fallback(ctx, false, "DecompressResponse requires a single stream")
+		case !rangeable:
+			err = f.fallback(ctx, rangeable, "server does not support ranges")
+		case f.size <= 0:
+			// the chunks can't be calculated without the size, eg. chunked responses
+			err = f.fallback(ctx, rangeable, fmt.Sprintf("size '%d' can't be split into ranges", f.size))
+		case !f.options.NoResume && f.hasPartial():
+			// a previously interrupted single stream download is resumed
+			// rather than starting over with a ranged download
+			err = f.fallback(ctx, rangeable, "resuming interrupted single stream download")
+		case f.size >= 0 && f.size < f.options.minSizeForRanges():
+			err = f.fallback(ctx, rangeable, fmt.Sprintf("size '%d' below MinSizeForRanges '%d'", f.size, f.options.minSizeForRanges()))
+		case f.options.RangeDecision != nil && !f.options.RangeDecision(f.size, rtt):
+			err = f.fallback(ctx, rangeable, fmt.Sprintf("RangeDecision chose a single stream for size '%d' and rtt '%s'", f.size, rtt))
+		default:
+			err = f.downloadRanges(ctx)
+		}
 	}
 
-	for i = 0; i < goroutines; i++ {
-
-		select {
-		case <-ctx.Done():
+	return err
+}
 
-			if ctx.Err() == context.Canceled {
-				err = &Canceled{url: f.url}
-			} else {
-				// context.DeadlineExceeded
-				err = &DeadlineExceeded{url: f.url}
-			}
+// headRequest makes the HEAD request of the file returning the response, with it's body
+// closed, and the round trip time which is used as an estimate for the RangeDecision
+func (f *File) headRequest(ctx context.Context) (*http.Response, time.Duration, error) {
 
-			//drain remaining
-			for ; i < goroutines; i++ {
-				res := <-ch
-				f.readers[res.idx] = res.r
-				break
-			}
+	spanCtx, endSpan := f.tracer().StartSpan(ctx, "head")
+	defer endSpan()
 
-		case res := <-ch:
+	req, err := f.newRequest(spanCtx, http.MethodHead, f.url)
+	if err != nil {
+		return nil, 0, err
+	}
 
-			f.readers[res.idx] = res.r
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
 
-			if err != nil {
-				continue
-			}
+	sent := time.Now()
 
-			if res.err != nil {
-				err = res.err
-			}
-		}
+	resp, err := f.do(req)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	close(ch)
+	f.redirected(resp)
 
-	readers := make([]io.Reader, len(f.readers))
-	for i = 0; i < len(f.readers); i++ {
-		readers[i] = f.readers[i]
-	}
+	rtt := time.Since(sent)
+	resp.Body.Close()
 
-	f.Reader = io.MultiReader(readers...)
-	f.modTime = time.Now()
-	return
+	return resp, rtt, nil
 }
 
-func (f *File) downloadPartial(ctx context.Context, resumeable bool, idx int, start, end int64, ch chan<- partialResult) {
+// within runs fn bounded by Options.MaxElapsed, if set, wrapping the error in a *DeadlineExceeded
+// error when it ran out of time, eg. the last error of a chunk that was being retried
+func (f *File) within(ctx context.Context, fn func(ctx context.Context) error) error {
 
-	var err error
-	var fh *os.File
+	if f.options.MaxElapsed <= 0 {
+		return fn(ctx)
+	}
 
-	defer func() {
-		ch <- partialResult{idx: idx, err: err, r: fh}
-	}()
+	ctx, cancel := context.WithTimeout(ctx, f.options.MaxElapsed)
+	defer cancel()
 
-	fPath := filepath.Join(f.dir, strconv.Itoa(idx))
+	err := fn(ctx)
 
-	if resumeable {
-		var fi os.FileInfo
+	if err != nil && ctx.Err() == context.DeadlineExceeded && !isContextErr(err) {
+		return &DeadlineExceeded{url: f.url, err: err}
+	}
 
-		fi, err = os.Stat(fPath)
-		if os.IsNotExist(err) {
-			fh, err = os.Create(fPath)
-		} else {
+	return err
+}
 
-			// file exists...musts check if partial
-			if fi.Size() < (end-start)+1 {
+// OpenSize downloads and opens the file(s) downloaded by the given url, of the given size, without
+// making a HEAD request. Range support is determined by requesting the first byte of the file,
+// making it useful when the size is already known and the number of requests should be kept minimal.
+// The context provided must be non-nil
+func OpenSize(ctx context.Context, url string, size int64, options *Options) (*File, error) {
 
-				// lets append/download only the bytes necessary
-				start += fi.Size()
-				fh, err = os.OpenFile(fPath, os.O_RDWR|os.O_APPEND, fileMode)
-			} else {
-				fh, err = os.Open(fPath)
-				return // if error or not still leaving
-			}
-		}
-	} else {
-		fh, err = os.Create(fPath)
+	if ctx == nil {
+		panic("nil context")
 	}
 
+	f, err := newFile(url, options)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	var client http.Client
-	if f.options != nil && f.options.Client != nil {
-		client = f.options.Client()
+	f.size = size
+
+	// the size is of no use to the other protocols' single stream downloads
+	if download, ok := f.protocol(); ok {
+		return f.opened(ctx, download(f, ctx))
 	}
 
-	var req *http.Request
-	if req, err = http.NewRequest(http.MethodGet, f.url, nil); err != nil {
-		return
+	if size < f.options.minSizeForRanges() {
+		return f.opened(ctx, f.fallback(ctx, false, fmt.Sprintf("size '%d' below MinSizeForRanges '%d'", f.size, f.options.minSizeForRanges())))
 	}
-	req = req.WithContext(ctx)
-	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
 
-	if f.options != nil && f.options.Request != nil {
-		f.options.Request(req)
+	if _, err = f.probeSize(ctx); err != nil {
+
+		if _, ok := err.(*InvalidResponseCode); !ok {
+			return nil, err
+		}
+
+		return f.opened(ctx, f.fallback(ctx, false, "server does not support ranges"))
 	}
 
-	var resp *http.Response
+	return f.opened(ctx, f.downloadRanges(ctx))
+}
+
+// OpenLazy opens the file(s) downloaded by the given url without downloading them, making only a
+// HEAD request for Stat, none for ftp:// urls whose size is unknown until read. The download
+// happens on the first Read, WriteTo, ReadAt or Seek, which returns any download error, saving
+// the bandwidth when the File may never be read.
+// The context provided must be non-nil and is used for the deferred download.
+func OpenLazy(ctx context.Context, url string, options *Options) (*File, error) {
 
-	if resp, err = client.Do(req); err != nil {
-		return
+	if ctx == nil {
+		panic("nil context")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusPartialContent {
-		err = &InvalidResponseCode{got: resp.StatusCode, expected: http.StatusPartialContent}
-		return
+	f, err := newFile(url, options)
+	if err != nil {
+		return nil, err
 	}
 
-	// check for timeout or cancellation before heaviest operation
-	select {
-	case <-ctx.Done():
-		return
-	default:
+	f.size = -1
+
+	// the other protocols have no HEAD request, their Stat is only known once downloaded
+	if _, ok := f.protocol(); !ok {
+		if err = f.lazyHead(ctx); err != nil {
+			return nil, err
+		}
 	}
 
-	var read io.Reader = resp.Body
+	f.modTime = f.remoteModTime()
 
-	if f.options != nil && f.options.Proxy != nil {
-		read = f.options.Proxy(f.baseName, idx, (end-start)+1, read)
+	f.lazy = &lazyLoad{fn: func() error {
+
+		// downloaded by a copy of the File as verifying the download reads it,
+		// which would otherwise wait for the load in progress
+		d := new(File)
+		*d = *f
+		d.lazy = nil
+		d.started = time.Now()
+
+		// the partially downloaded chunks of a cancelled download are kept too
+		file, err := d.opened(ctx, d.within(ctx, d.open))
+		if file != nil {
+			f.adopt(d)
+		}
+
+		return err
+	}}
+
+	return f, nil
+}
+
+// lazyLoad is the deferred download of a File opened using OpenLazy, run once
+type lazyLoad struct {
+	once sync.Once
+	fn   func() error
+	err  error
+}
+
+// lazyHead makes the HEAD request of OpenLazy for Stat, kept for the download to reuse
+func (f *File) lazyHead(ctx context.Context) error {
+
+	if f.options.UpgradeToHTTPS {
+		f.upgradeToHTTPS(ctx)
 	}
 
-	if _, err = io.Copy(fh, read); err != nil {
-		return
+	resp, rtt, err := f.headRequest(ctx)
+	if err != nil {
+		return err
 	}
 
-	fh.Seek(0, 0)
-}
+	f.head, f.headRTT = resp, rtt
 
-// Stat returns the FileInfo structure describing file(s). If there is an error, it will be of type *PathError.
-func (f *File) Stat() (os.FileInfo, error) {
+	if resp.StatusCode == http.StatusOK {
+		f.size = resp.ContentLength
+		f.mimeType = resp.Header.Get("Content-Type")
+		f.lastModified = resp.Header.Get("Last-Modified")
 
-	if f.modTime.IsZero() {
-		return nil, &os.PathError{Op: "stat", Path: filepath.Base(f.url), Err: errors.New("bad file descriptor")}
+		if name := dispositionName(resp.Header); name != "" {
+			f.baseName = name
+		}
 	}
 
-	return &fileInfo{
-		name:    filepath.Base(f.url),
-		size:    f.size,
-		mode:    fileMode,
-		modTime: f.modTime,
-	}, nil
+	return nil
 }
 
-// Close closes the File(s), rendering it unusable for I/O. It returns an error, if any.
-func (f *File) Close() error {
+// load runs the deferred download of a File opened using OpenLazy, if not already run,
+// returning it's error. Concurrent calls wait for the one download.
+func (f *File) load() error {
 
-	f.closeFileHandles()
-	f.modTime = defaultTime
+	if f.lazy == nil {
+		return nil
+	}
+
+	f.lazy.once.Do(func() {
+		f.lazy.err = f.lazy.fn()
+	})
 
-	return os.RemoveAll(f.dir)
+	return f.lazy.err
 }
 
-func (f *File) closeFileHandles() {
-	for i := 0; i < len(f.readers); i++ {
-		if f.readers[i] != nil { // possible if cancelled or error occured
-			f.readers[i].Close()
-		}
-	}
+// adopt takes on the download state of d, the copy of the File that was lazily downloaded
+func (f *File) adopt(d *File) {
+
+	f.url = d.url
+	f.dir = d.dir
+	f.baseName = d.baseName
+	f.size = d.size
+	f.modTime = d.modTime
+	f.mimeType = d.mimeType
+	f.readers = d.readers
+	f.chunks = d.chunks
+	f.pos = d.pos
+	f.aggregate = d.aggregate
+	f.maxConns = d.maxConns
+	f.offset = d.offset
+	f.total = d.total
+	f.head = nil
+	f.etag = d.etag
+	f.lastModified = d.lastModified
+	f.finalURL = d.finalURL
+	f.digest = d.digest
+	f.sparse = d.sparse
+	f.started = d.started
+	f.stats = d.stats
+	f.resumed = d.resumed
+	f.retries = d.retries
+	f.Reader = d.Reader
 }
 
-func (f *File) generateHash() string {
+func newFile(rawurl string, options *Options) (*File, error) {
 
-	// Open to a better way, but should not collide
-	h := sha1.New()
-	io.WriteString(h, f.url)
+	if options == nil {
+		options = new(Options)
+	}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
-}
+	if options.BaseURL != "" {
 
-// chunks up downloads into 2MB chunks, when Accept-Ranges supported
-func defaultConcurrencyFn(length int64) int {
-	return defaultGoroutines
+		ref, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ref.IsAbs() {
+
+			base, err := url.Parse(options.BaseURL)
+			if err != nil {
+				return nil, err
+			}
+
+			rawurl = base.ResolveReference(ref).String()
+		}
+	}
+
+	if options.Suffix > 0 {
+		if err := httpOnly(rawurl, "Suffix"); err != nil {
+			return nil, err
+		}
+	}
+
+	f := &File{
+		url:      rawurl,
+		baseName: filepath.Base(rawurl),
+		options:  options,
+		started:  time.Now(),
+	}
+
+	if options.MaxBytesPerSecond > 0 {
+		f.limiter = &rateLimiter{rate: options.MaxBytesPerSecond}
+	}
+
+	// an invalid LocalAddr is ignored, noted once per download
+	if options.LocalAddr != nil {
+		if _, err := net.ResolveTCPAddr("tcp", options.LocalAddr.String()); err != nil {
+			f.logf("notice: invalid LocalAddr '%s', ignoring: %s\n", options.LocalAddr, err)
+		}
+	}
+
+	return f, nil
+}
+
+// opened completes opening the downloaded file(s), cleaning up if the download or verification failed
+func (f *File) opened(ctx context.Context, err error) (*File, error) {
+
+	if err != nil {
+		f.debugf("download of '%s' failed: %s\n", f.url, err)
+		f.closeFileHandles()
+
+		// the partially downloaded chunks are returned for inspection
+		if f.chunks != nil && isContextErr(err) {
+			f.stats.Duration = time.Since(f.started)
+			return f, err
+		}
+
+		// the caller has no File to Close so nothing is kept unless asked for resuming
+		switch {
+		case f.dir != f.resumeDir():
+			os.RemoveAll(f.dir)
+		case f.options.NoResume || !f.options.KeepOnFailure:
+			f.removeResume()
+		}
+
+		return nil, err
+	}
+
+	if f.options.Coalesce {
+		if err = f.coalesce(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if f.options.Mmap {
+		if err = f.mmap(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if err = f.verify(ctx); err != nil {
+		f.debugf("verification of '%s' failed: %s\n", f.url, err)
+		f.Close()
+
+		// closing discarded the resumed download, the fresh one isn't resumed so is only tried once
+		if _, ok := err.(*ChecksumMismatch); ok && f.options.FreshOnChecksumFail && f.resumed > 0 {
+			f.logf("notice: resumed download of '%s' failed checksum verification, downloading afresh\n", f.url)
+			f.reset()
+			return f.opened(ctx, f.within(ctx, f.open))
+		}
+
+		return nil, err
+	}
+
+	f.stats.Duration = time.Since(f.started)
+
+	return f, nil
+}
+
+// reset discards the state of a failed download so that the File can be downloaded again
+func (f *File) reset() {
+
+	f.dir = ""
+	f.size = 0
+	f.modTime = time.Time{}
+	f.readers = nil
+	f.chunks = nil
+	f.pos = 0
+	f.aggregate = nil
+	f.offset = 0
+	f.total = 0
+	f.digest = ""
+	f.stats = DownloadStats{}
+	f.resumed = 0
+	f.retries = 0
+	f.Reader = nil
+}
+
+// newRequest returns a new request for the url, cloning the OpenRequest template if any
+func (f *File) newRequest(ctx context.Context, method, rawurl string) (*http.Request, error) {
+
+	if f.template == nil {
+		req, err := http.NewRequest(method, rawurl, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return req.WithContext(ctx), nil
+	}
+
+	req := f.template.Clone(ctx)
+	req.Method = method
+	req.Body = nil
+	req.GetBody = nil
+	req.ContentLength = 0
+	req.Header.Del("Range")
+
+	// redirected or resolved against the BaseURL
+	if rawurl != f.template.URL.String() {
+
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, err
+		}
+
+		req.URL = u
+		req.Host = ""
+	}
+
+	return req, nil
+}
+
+// redirected records the url the request of resp was redirected to, if any,
+// so that the following requests go there directly
+func (f *File) redirected(resp *http.Response) {
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		if u := resp.Request.URL.String(); u != f.url {
+			f.finalURL = u
+		}
+	}
+}
+
+// requestURL returns the url to request the file from, the url redirected to if on the same host.
+// A redirect to another host is followed again by each request so that, as for any redirect,
+// the Authorization and Cookie headers aren't sent to it.
+func (f *File) requestURL() string {
+
+	if f.finalURL != "" && sameHost(f.finalURL, f.url) {
+		return f.finalURL
+	}
+
+	return f.url
+}
+
+// sameHost returns if the urls are of the same host and port
+func sameHost(url1, url2 string) bool {
+
+	u1, err := url.Parse(url1)
+	if err != nil {
+		return false
+	}
+
+	u2, err := url.Parse(url2)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u1.Host, u2.Host)
+}
+
+// FinalURL returns the url the file was downloaded from, after following any redirects
+func (f *File) FinalURL() string {
+
+	if f.finalURL != "" {
+		return f.finalURL
+	}
+
+	return f.url
+}
+
+// remoteModTime returns the Last-Modified time of the remote file,
+// or the current time when unknown
+func (f *File) remoteModTime() time.Time {
+
+	if t, err := http.ParseTime(f.lastModified); err == nil {
+		return t
+	}
+
+	return time.Now()
+}
+
+// contextErr returns the download error for the done context
+func (f *File) contextErr(ctx context.Context) error {
+
+	if ctx.Err() == context.Canceled {
+		return &Canceled{url: f.url}
+	}
+
+	// context.DeadlineExceeded
+	return &DeadlineExceeded{url: f.url}
+}
+
+// isContextErr returns if err is the download error for a cancelled or timed out context
+func isContextErr(err error) bool {
+
+	switch err.(type) {
+	case *Canceled, *DeadlineExceeded:
+		return true
+	}
+
+	return false
+}
+
+// timeoutCause records a chunk's error, other than the timeout itself, as the cause of a timeout
+func timeoutCause(err, cause error) {
+
+	if e, ok := err.(*DeadlineExceeded); ok && e.err == nil && cause != nil && !errors.Is(cause, context.DeadlineExceeded) {
+		e.err = cause
+	}
+}
+
+// fallback downloads the file in a single stream rather than using ranges for the given reason
+func (f *File) fallback(ctx context.Context, rangeable bool, reason string) error {
+
+	f.debugf("single stream download: %s\n", reason)
+
+	if f.options.OnFallback != nil {
+		f.options.OnFallback(reason)
+	}
+
+	return f.download(ctx, rangeable)
+}
+
+// downloadRanges downloads the file using ranges, falling back to a single stream
+// when the server ignores the ranges and Options.FallbackOnIgnoredRange is set
+func (f *File) downloadRanges(ctx context.Context) error {
+
+	err := f.downloadRangeBytes(ctx)
+
+	var rangeErr *RangeNotSupported
+
+	if err == nil || !f.options.FallbackOnIgnoredRange || !errors.As(err, &rangeErr) {
+		return err
+	}
+
+	f.closeFileHandles()
+
+	if f.dir != "" {
+		f.removeResume()
+	}
+
+	f.dir = ""
+	f.readers = nil
+	f.chunks = nil
+	f.aggregate = nil
+
+	return f.fallback(ctx, false, "server ignored the ranged request")
+}
+
+// preflight sends an OPTIONS request returning the response headers as hints
+// of the allowed methods and ranges, nil when the response was unsuccessful
+func (f *File) preflight(ctx context.Context) (http.Header, error) {
+
+	spanCtx, endSpan := f.tracer().StartSpan(ctx, "preflight")
+	defer endSpan()
+
+	req, err := f.newRequest(spanCtx, http.MethodOptions, f.url)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		f.logf("notice: unexpected OPTIONS response code '%d', ignoring preflight.\n", resp.StatusCode)
+		return nil, nil
+	}
+
+	return resp.Header, nil
+}
+
+// upgradeToHTTPS switches an http url to https when a HEAD request of the https url succeeds
+func (f *File) upgradeToHTTPS(ctx context.Context) {
+
+	u, err := url.Parse(f.url)
+	if err != nil || u.Scheme != "http" {
+		return
+	}
+
+	u.Scheme = "https"
+
+	req, err := f.newRequest(ctx, http.MethodHead, u.String())
+	if err != nil {
+		return
+	}
+
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		f.debugf("https HEAD failed, falling back to http: %s\n", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		f.debugf("unexpected https HEAD response code '%d', falling back to http\n", resp.StatusCode)
+		return
+	}
+
+	f.url = u.String()
+}
+
+// allowsMethod returns if the Allow header(s) include the method
+func allowsMethod(h http.Header, method string) bool {
+
+	for _, v := range h.Values("Allow") {
+		for _, m := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(m), method) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// acceptsRanges returns if the Accept-Ranges header(s) include bytes,
+// ignoring case and whitespace
+func acceptsRanges(h http.Header) bool {
+
+	for _, v := range h.Values("Accept-Ranges") {
+		for _, unit := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// dispositionName returns the file name from the Content-Disposition header, if any,
+// stripped of any directories so it's safe to use as a local file name
+func dispositionName(h http.Header) string {
+
+	_, params, err := mime.ParseMediaType(h.Get("Content-Disposition"))
+	if err != nil {
+		return ""
+	}
+
+	name := filepath.Base(filepath.FromSlash(strings.Replace(params["filename"], "\\", "/", -1)))
+
+	switch name {
+	case ".", "..", string(filepath.Separator):
+		return ""
+	}
+
+	return name
+}
+
+// probeSize requests the first byte of the file to determine
+// the total size from the returned Content-Range.
+func (f *File) probeSize(ctx context.Context) (int64, error) {
+
+	req, err := f.newRequest(ctx, http.MethodGet, f.requestURL())
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Add("Range", "bytes=0-0")
+
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, &InvalidResponseCode{got: resp.StatusCode, expected: http.StatusPartialContent}
+	}
+
+	_, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return 0, err
+	}
+
+	if total < 0 {
+		return 0, fmt.Errorf("Unknown Content-Range total '%s'", resp.Header.Get("Content-Range"))
+	}
+
+	return total, nil
+}
+
+// parseContentRange parses a Content-Range header value of the form "bytes start-end/total",
+// total is -1 when the server doesn't know it.
+func parseContentRange(s string) (start, end, total int64, err error) {
+
+	invalid := fmt.Errorf("Invalid Content-Range '%s'", s)
+
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, 0, invalid
+	}
+
+	s = strings.TrimPrefix(s, "bytes ")
+
+	slash := strings.IndexByte(s, '/')
+	dash := strings.IndexByte(s, '-')
+
+	if slash == -1 || dash == -1 || dash > slash {
+		return 0, 0, 0, invalid
+	}
+
+	if start, err = strconv.ParseInt(s[:dash], 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+
+	if end, err = strconv.ParseInt(s[dash+1:slash], 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+
+	if s[slash+1:] == "*" {
+		return start, end, -1, nil
+	}
+
+	if total, err = strconv.ParseInt(s[slash+1:], 10, 64); err != nil {
+		return 0, 0, 0, invalid
+	}
+
+	return start, end, total, nil
+}
+
+// download downloads the file in a single stream, if a previous attempt was interrupted
+// and rangeable is true only the remaining bytes are requested.
+func (f *File) download(ctx context.Context, rangeable bool) error {
+
+	partial := filepath.Join(f.resumeDir(), partialName)
+
+	var offset int64
+
+	if fi, err := os.Stat(partial); err == nil && !f.options.InMemory {
+		if rangeable && !f.options.NoResume && f.worthResuming(fi.Size()) {
+			offset = fi.Size()
+		} else {
+			os.Remove(partial)
+		}
+	}
+
+	req, err := f.newRequest(ctx, http.MethodGet, f.requestURL())
+	if err != nil {
+		return err
+	}
+
+	if offset > 0 {
+		f.debugf("resuming single stream download from byte '%d'\n", offset)
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	f.redirected(resp)
+
+	var complete bool
+
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+	case offset > 0 && offset == f.size && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		complete = true // the interrupted download had already finished
+	case resp.StatusCode == http.StatusOK:
+		offset = 0 // server sent the whole file
+	default:
+		return &InvalidResponseCode{got: resp.StatusCode, expected: http.StatusOK}
+	}
+
+	if resp.ContentLength >= 0 {
+		if err = f.checkMaxSize(resp.Header, offset+resp.ContentLength); err != nil {
+			return err
+		}
+	}
+
+	if t := resp.Header.Get("Content-Type"); t != "" && !complete {
+		f.mimeType = t
+	}
+
+	if name := dispositionName(resp.Header); name != "" {
+		f.baseName = name
+	}
+
+	// the HEAD request may have failed
+	if lm := resp.Header.Get("Last-Modified"); lm != "" && f.lastModified == "" {
+		f.lastModified = lm
+	}
+
+	var body io.Reader = resp.Body
+	length := resp.ContentLength
+
+	if complete {
+		body = http.NoBody
+	}
+
+	if f.options.DecompressResponse && !resp.Uncompressed {
+
+		var decompressed bool
+
+		if body, decompressed, err = decompress(resp.Header.Get("Content-Encoding"), body); err != nil {
+			return err
+		}
+
+		// the lengths are of the compressed content
+		if decompressed {
+			f.size = -1
+			length = -1
+		}
+	}
+
+	if err = f.receive(ctx, body, offset, length); err != nil {
+		return err
+	}
+
+	// the trailers are only available once the body has been read
+	if digest := trailerDigest(resp.Trailer); digest != "" {
+		f.digest = digest
+	}
+
+	// the resume metadata, and any digest stored in it, was removed for the download,
+	// keeping the digest lets the next download of the url be verified against it
+	if f.digest != "" {
+		f.writeResumeMetadata()
+	}
+
+	return nil
+}
+
+// receive writes the single stream download body, the remainder of the file from offset, to
+// a temporary file or memory and makes it the File's content. length is that of the body, if known.
+func (f *File) receive(ctx context.Context, body io.Reader, offset, length int64) error {
+
+	partial := filepath.Join(f.resumeDir(), partialName)
+
+	var fh *os.File
+	var buf *bytes.Buffer
+	var dst io.Writer
+	var err error
+
+	if f.options.InMemory {
+
+		buf = new(bytes.Buffer)
+		if length > 0 {
+			buf.Grow(int(length))
+		}
+
+		dst = buf
+	} else {
+
+		if fh, err = f.openDownloadFile(partial, offset); err != nil {
+			return err
+		}
+
+		f.readers = []chunkReader{fh}
+		dst = fh
+	}
+
+	read := f.limitRate(ctx, body)
+
+	if f.options.Proxy != nil {
+
+		size := f.size
+		if size > 0 {
+			size -= offset
+		}
+
+		read = f.options.Proxy(f.baseName, 0, size, read)
+	}
+
+	if f.options.Progress != nil && f.options.AggregateProgress {
+		f.aggregate = f.newAggregate()
+		f.aggregate.add(offset)
+	}
+
+	read = f.trackProgress(0, offset, f.size, read)
+
+	n, err := f.copy(dst, read)
+
+	f.stats.Concurrency = 1
+	f.stats.ChunkBytes = []int64{n}
+	f.resumed = offset
+
+	if err != nil {
+		if fh != nil {
+			f.savePartial(fh.Name())
+		}
+		return err
+	}
+
+	var r chunkReader = fh
+
+	if buf != nil {
+		r = newMemChunk(buf.Bytes())
+		f.readers = []chunkReader{r}
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	// the size is unknown when the HEAD request failed or the response was decompressed
+	if f.size <= 0 {
+		f.size = size
+	}
+
+	f.chunks = []chunk{{start: 0, end: size - 1}}
+
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	f.Reader = r
+	f.modTime = f.remoteModTime()
+
+	return nil
+}
+
+// openDownloadFile creates the file for a single stream download, moving
+// the partial into it when resuming from offset
+func (f *File) openDownloadFile(partial string, offset int64) (*os.File, error) {
+
+	var err error
+
+	f.dir, err = ioutil.TempDir(f.options.tempDir(), defaultDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fh, err := f.createTempFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+
+		fh.Close()
+
+		if err = os.Rename(partial, fh.Name()); err != nil {
+			return nil, err
+		}
+
+		if fh, err = os.OpenFile(fh.Name(), os.O_RDWR|os.O_APPEND, fileMode); err != nil {
+			return nil, err
+		}
+	}
+
+	// partial was moved or is no longer needed
+	f.removeResume()
+
+	return fh, nil
+}
+
+// createTempFile creates the file for a single stream download in the download directory
+func (f *File) createTempFile() (*os.File, error) {
+
+	if f.options.TempFileName == nil {
+		return ioutil.TempFile(f.dir, "")
+	}
+
+	name, err := f.options.TempFileName(f.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+}
+
+// savePartial moves the interrupted single stream download to the resume
+// directory so that the next download of the same url can resume it.
+func (f *File) savePartial(name string) {
+
+	if err := os.MkdirAll(f.resumeDir(), fileMode); err != nil {
+		return
+	}
+
+	if err := os.Rename(name, filepath.Join(f.resumeDir(), partialName)); err != nil {
+		return
+	}
+
+	f.writeResumeMetadata()
+
+	os.RemoveAll(f.dir)
+}
+
+// checkMaxSize returns a *MaxSizeExceeded error when size is larger than the maximum
+// declared by the Options.MaxSizeHeader response header, if any.
+func (f *File) checkMaxSize(h http.Header, size int64) error {
+
+	if f.options.MaxSizeHeader == "" || size < 0 {
+		return nil
+	}
+
+	v := h.Get(f.options.MaxSizeHeader)
+	if v == "" {
+		return nil
+	}
+
+	max, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		f.logf("notice: invalid '%s' header value '%s', ignoring.\n", f.options.MaxSizeHeader, v)
+		return nil
+	}
+
+	if size > max {
+		return &MaxSizeExceeded{max: max, got: size}
+	}
+
+	return nil
+}
+
+// hasPartial returns if there is an interrupted single stream download to resume
+func (f *File) hasPartial() bool {
+	_, err := os.Stat(filepath.Join(f.resumeDir(), partialName))
+	return err == nil
+}
+
+func (f *File) downloadRangeBytes(ctx context.Context) (err error) {
+
+	if f.size <= 0 {
+		return fmt.Errorf("Invalid content length '%d'", f.size)
+	}
+
+	var goroutines int
+
+	switch {
+	case f.options.BandwidthConcurrency != nil:
+		goroutines = f.options.BandwidthConcurrency(f.size, f.estimateBandwidth(ctx))
+	case f.options.Concurrency != nil:
+		goroutines = f.options.Concurrency(f.size)
+	}
+
+	if goroutines < 1 {
+		goroutines = defaultConcurrencyFn(f.size)
+	}
+
+	// the server's advertised limit wins over the requested concurrency
+	if f.maxConns > 0 && goroutines > f.maxConns {
+		goroutines = f.maxConns
+	}
+
+	// each chunk must be at least one byte or the chunk size becomes zero
+	if int64(goroutines) > f.size {
+		goroutines = int(f.size)
+	}
+
+	var chunks []chunk
+
+	if f.options.ExponentialChunks {
+		chunks = exponentialChunks(f.size, goroutines)
+	} else {
+		chunks = equalChunks(f.size, goroutines)
+	}
+
+	f.chunks = chunks
+
+	var resume bool
+
+	// in memory downloads have no directory and so can't be resumed
+	if !f.options.InMemory {
+
+		f.dir = f.resumeDir()
+
+		if f.options.NoResume {
+			if err = f.removeResume(); err != nil {
+				return
+			}
+		}
+
+		if _, err = os.Stat(f.dir); err == nil && !f.worthResuming(f.resumedSize()) {
+			if err = f.removeResume(); err != nil {
+				return
+			}
+		}
+
+		// the chunks are resumed by index so the interrupted download's layout is
+		// kept, it's concurrency may have differed, or discarded when unusable
+		if _, err = os.Stat(f.dir); err == nil {
+
+			stored, layoutErr := f.storedChunks()
+
+			switch {
+			case layoutErr != nil:
+				f.debugf("discarding the interrupted download: %s\n", layoutErr)
+				if err = f.removeResume(); err != nil {
+					return
+				}
+			case stored != nil:
+				f.chunks = stored
+			}
+		}
+
+		if _, err = os.Stat(f.dir); os.IsNotExist(err) {
+			err = os.Mkdir(f.dir, fileMode) // only owner and group have RWX access
+			if err != nil {
+				return
+			}
+		} else {
+			resume = true
+			f.debugf("resuming ranged download from '%s'\n", f.dir)
+		}
+
+		if err = f.writeResumeMetadata(); err != nil {
+			return
+		}
+	}
+
+	return f.downloadChunks(ctx, resume)
+}
+
+// downloadChunks downloads the File's chunks concurrently, resuming them from the download
+// directory when resume is true, reading them one after another once downloaded.
+func (f *File) downloadChunks(ctx context.Context, resume bool) (err error) {
+
+	chunks := f.chunks
+	goroutines := len(chunks)
+
+	f.readers = make([]chunkReader, goroutines, goroutines)
+
+	f.stats.Ranged = true
+	f.stats.ChunkBytes = make([]int64, goroutines)
+	f.stats.Concurrency = goroutines
+
+	if f.options.MaxParallel > 0 && f.options.MaxParallel < goroutines {
+		f.stats.Concurrency = f.options.MaxParallel
+	}
+
+	f.debugf("ranged download of '%d' chunks, '%d' at once\n", goroutines, f.stats.Concurrency)
+
+	if f.options.Progress != nil && f.options.AggregateProgress {
+		f.aggregate = f.newAggregate()
+	}
+
+	// buffered so that finished chunks free their MaxParallel slot before being collected
+	ch := make(chan partialResult, goroutines)
+
+	// the chunk downloads are cancelled as soon as one fails,
+	// parent is used to detect the caller cancelling
+	parent := ctx
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var ticker *time.Ticker
+
+	if f.options.ChunkLaunchRate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / f.options.ChunkLaunchRate))
+		defer ticker.Stop()
+	}
+
+	var sem chan struct{}
+
+	if f.options.MaxParallel > 0 {
+		sem = make(chan struct{}, f.options.MaxParallel)
+	}
+
+	var i, launched int
+
+	for ; i < goroutines; i++ {
+
+		if ticker != nil && i > 0 {
+
+			select {
+			case <-parent.Done():
+				err = f.contextErr(parent)
+			case <-ticker.C:
+			}
+
+			if err != nil {
+				break
+			}
+		}
+
+		idx := i
+		if f.options.ReverseOrder {
+			idx = goroutines - 1 - i
+		}
+
+		// wait for each chunk to be opened before launching the next so that
+		// the chunks are fetched in the order they were scheduled
+		opened := make(chan struct{})
+
+		if sem != nil {
+
+			select {
+			case <-ctx.Done():
+			case sem <- struct{}{}:
+			}
+
+			// a chunk failed or the download was cancelled while waiting
+			if ctx.Err() != nil {
+				if parent.Err() != nil {
+					err = f.contextErr(parent)
+				}
+				break
+			}
+		}
+
+		go func() {
+			f.downloadPartial(ctx, resume, idx, chunks[idx].start, chunks[idx].end, opened, ch)
+
+			if sem != nil {
+				<-sem
+			}
+		}()
+
+		<-opened
+		launched++
+	}
+
+	for i = 0; i < launched; i++ {
+
+		select {
+		case <-parent.Done():
+
+			if !isContextErr(err) {
+				err = f.contextErr(parent)
+			}
+
+			//drain remaining
+			res := <-ch
+			f.readers[res.idx] = res.r
+			timeoutCause(err, res.err)
+
+		case res := <-ch:
+
+			f.readers[res.idx] = res.r
+
+			if err != nil {
+				timeoutCause(err, res.err)
+				continue
+			}
+
+			if res.err != nil {
+				err = res.err
+
+				if !f.options.DrainOnError {
+					cancel()
+				}
+			}
+		}
+	}
+
+	close(ch)
+
+	readers := make([]io.Reader, 0, len(f.readers))
+	for i = 0; i < len(f.readers); i++ {
+		// chunks not started when cancelled have no reader
+		if f.readers[i] != nil {
+			readers = append(readers, f.readers[i])
+		}
+	}
+
+	f.Reader = io.MultiReader(readers...)
+	f.modTime = f.remoteModTime()
+	return
+}
+
+func (f *File) downloadPartial(ctx context.Context, resumeable bool, idx int, start, end int64, opened chan<- struct{}, ch chan<- partialResult) {
+
+	var err error
+	var fh *os.File
+	var r chunkReader
+	var complete bool
+
+	chunkStart := start
+
+	defer func() {
+
+		if err != nil {
+			err = &ChunkError{idx: idx, start: chunkStart, end: end, err: err}
+			f.debugf("chunk %d failed: %s\n", idx, err)
+		} else {
+			f.debugf("chunk %d finished\n", idx)
+		}
+
+		// the chunk file is reopened on demand when read so
+		// that only a single file needs to be open at a time
+		if fh != nil {
+			fh.Close()
+			r = &lazyFile{name: fh.Name()}
+
+			if err == nil && f.options.OnChunkFile != nil {
+				f.options.OnChunkFile(idx, fh.Name())
+			}
+		}
+
+		ch <- partialResult{idx: idx, err: err, r: r}
+	}()
+
+	ctx, endSpan := f.tracer().StartSpan(ctx, fmt.Sprintf("chunk %d", idx))
+	defer endSpan()
+
+	f.debugf("chunk %d started, bytes '%d-%d'\n", idx, start, end)
+
+	if f.options.InMemory {
+
+		close(opened)
+
+		buf := new(bytes.Buffer)
+		buf.Grow(int((end - start) + 1))
+
+		err = f.fetchPartialRetry(ctx, idx, start, start, end, buf)
+		r = newMemChunk(buf.Bytes())
+		return
+	}
+
+	fh, start, complete, err = f.openPartial(resumeable, idx, start, end)
+	close(opened)
+
+	if err != nil {
+		return
+	}
+
+	resumed := start - chunkStart
+	if complete {
+		resumed = (end - start) + 1
+	}
+
+	atomic.AddInt64(&f.resumed, resumed)
+
+	if f.aggregate != nil {
+		f.aggregate.add(resumed)
+	}
+
+	if complete {
+		return
+	}
+
+	err = f.fetchPartialRetry(ctx, idx, chunkStart, start, end, fh)
+}
+
+// fetchPartialRetry fetches the remaining start-end bytes of the chunk starting at chunkStart,
+// retrying up to Options.MaxRetries times requesting only the bytes not yet written to w.
+func (f *File) fetchPartialRetry(ctx context.Context, idx int, chunkStart, start, end int64, w io.Writer) error {
+
+	var last error
+
+	for attempt := 1; ; attempt++ {
+
+		n, err := f.fetchMirrors(ctx, idx, chunkStart, start, end, w)
+		start += n
+
+		// each chunk's goroutine only updates it's own count
+		if idx < len(f.stats.ChunkBytes) {
+			f.stats.ChunkBytes[idx] += n
+		}
+
+		// a retry interrupted by the context is less telling than the error that caused it
+		if err != nil && ctx.Err() != nil && last != nil {
+			return last
+		}
+
+		if err == nil || attempt > f.options.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		last = err
+		atomic.AddInt64(&f.retries, 1)
+
+		f.debugf("chunk %d attempt %d failed, retrying: %s\n", idx, attempt, err)
+
+		// retrying won't change the server's response
+		switch err.(type) {
+		case *RangeNotSupported, *ContentChanged:
+			return err
+		}
+
+		timer := time.NewTimer(f.options.retryBackoff(attempt))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// fetchPartial requests the inclusive byte range start-end of the chunk starting at
+// chunkStart from rawurl, appending it to w and returning the number of bytes written.
+func (f *File) fetchPartial(ctx context.Context, idx int, chunkStart, start, end int64, w io.Writer, rawurl string) (int64, error) {
+
+	req, err := f.newRequest(ctx, http.MethodGet, rawurl)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	if f.options.QueryModifier != nil {
+		req.URL.RawQuery = f.options.QueryModifier(req.URL.Query(), idx, start, end).Encode()
+	}
+
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return 0, &RangeNotSupported{url: f.url}
+	default:
+		return 0, &InvalidResponseCode{got: resp.StatusCode, expected: http.StatusPartialContent}
+	}
+
+	gotStart, gotEnd, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return 0, err
+	}
+
+	if gotStart != start || gotEnd != end {
+		return 0, &RangeMismatch{expected: chunk{start: start, end: end}, got: chunk{start: gotStart, end: gotEnd}}
+	}
+
+	// the chunks must all come from the same version of the file, the ETags of mirrors may differ
+	if etag := resp.Header.Get("ETag"); etag != "" && f.etag != "" && etag != f.etag && rawurl == f.requestURL() {
+		return 0, &ContentChanged{url: f.url, expected: f.etag, got: etag}
+	}
+
+	// check for timeout or cancellation before heaviest operation
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	read := f.limitRate(ctx, resp.Body)
+
+	if f.options.Proxy != nil {
+		read = f.options.Proxy(f.baseName, idx, (end-start)+1, read)
+	}
+
+	read = f.trackProgress(idx, start-chunkStart, (end-chunkStart)+1, read)
+
+	return f.copy(w, read)
+}
+
+// equalChunks partitions size into n equally sized, inclusive, chunks the last also
+// containing the remainder. n must be between 1 and size.
+func equalChunks(size int64, n int) []chunk {
+
+	chunkSize := size / int64(n)
+	chunks := make([]chunk, n)
+
+	for i := 0; i < n; i++ {
+
+		start := int64(i) * chunkSize
+		end := start + chunkSize - 1
+
+		if i == n-1 {
+			end = size - 1 // add remainder to last download
+		}
+
+		chunks[i] = chunk{start: start, end: end}
+	}
+
+	return chunks
+}
+
+// exponentialChunks partitions size into n chunks, each roughly double the size of the previous
+func exponentialChunks(size int64, n int) []chunk {
+
+	chunks := make([]chunk, n)
+	var start int64
+
+	for i := 0; i < n; i++ {
+
+		// fraction of the file covered by the first i+1 chunks, (2^(i+1) - 1) / (2^n - 1)
+		// rearranged so that it doesn't overflow for large values of n
+		frac := (math.Exp2(float64(i+1-n)) - math.Exp2(float64(-n))) / (1 - math.Exp2(float64(-n)))
+		end := int64(float64(size)*frac) - 1
+
+		// each chunk must be at least one byte, leaving at least one byte for each remaining chunk
+		if end < start {
+			end = start
+		}
+
+		if max := size - int64(n-i); end > max {
+			end = max
+		}
+
+		if i == n-1 {
+			end = size - 1
+		}
+
+		chunks[i] = chunk{start: start, end: end}
+		start = end + 1
+	}
+
+	return chunks
+}
+
+// openPartial opens the chunk file for the partial download, when resuming it returns the
+// adjusted start position of the remaining bytes or if the chunk is already complete.
+func (f *File) openPartial(resumeable bool, idx int, start, end int64) (fh *os.File, newStart int64, complete bool, err error) {
+
+	fPath := filepath.Join(f.dir, strconv.Itoa(idx))
+
+	if !resumeable {
+		fh, err = os.Create(fPath)
+		return fh, start, false, err
+	}
+
+	var fi os.FileInfo
+
+	fi, err = os.Stat(fPath)
+	if os.IsNotExist(err) {
+		// nothing downloaded yet
+		fh, err = os.Create(fPath)
+		return fh, start, false, err
+	}
+
+	if err != nil {
+		return nil, start, false, err
+	}
+
+	// file exists...musts check if partial
+	if fi.Size() < (end-start)+1 {
+
+		// lets append/download only the bytes necessary
+		fh, err = os.OpenFile(fPath, os.O_RDWR|os.O_APPEND, fileMode)
+		return fh, start + fi.Size(), false, err
+	}
+
+	fh, err = os.Open(fPath)
+	return fh, start, true, err
+}
+
+// Stat returns the FileInfo structure describing file(s). If there is an error, it will be of type *PathError.
+func (f *File) Stat() (os.FileInfo, error) {
+
+	if f.modTime.IsZero() {
+		return nil, &os.PathError{Op: "stat", Path: f.baseName, Err: errors.New("bad file descriptor")}
+	}
+
+	return &fileInfo{
+		name:    f.baseName,
+		size:    f.size,
+		mode:    fileMode,
+		modTime: f.modTime,
+	}, nil
+}
+
+// RefreshStat requests the current information about the remote file, eg. to check if it has
+// changed since being downloaded, honouring the context deadline. Unlike Stat the returned
+// os.FileInfo describes the remote file, it's Sys method returns the http.Header of the
+// response for access to the ETag etc. The downloaded file itself is left unchanged.
+func (f *File) RefreshStat(ctx context.Context) (os.FileInfo, error) {
+
+	if err := httpOnly(f.url, "RefreshStat"); err != nil {
+		return nil, err
+	}
+
+	req, err := f.newRequest(ctx, http.MethodHead, f.url)
+	if err != nil {
+		return nil, err
+	}
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, f.contextErr(ctx)
+		}
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &InvalidResponseCode{got: resp.StatusCode, expected: http.StatusOK}
+	}
+
+	modTime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		modTime = time.Now()
+	}
+
+	return &fileInfo{
+		name:    f.baseName,
+		size:    resp.ContentLength,
+		mode:    fileMode,
+		modTime: modTime,
+		sys:     resp.Header,
+	}, nil
+}
+
+// Close closes the File(s), rendering it unusable for I/O. It returns an error, if any.
+//
+// The size of the downloaded file(s) is reconciled against the expected size, logging a
+// warning on mismatch or when Options.VerifyOnClose is set returning a *ShortDownload error.
+func (f *File) Close() error {
+
+	// a lazily opened File closed before being read is never downloaded
+	if f.lazy != nil {
+		f.lazy.once.Do(func() {
+			f.lazy.err = os.ErrClosed
+		})
+	}
+
+	err := f.reconcile()
+
+	f.closeFileHandles()
+	f.modTime = defaultTime
+
+	rmErr := os.RemoveAll(f.dir)
+
+	// a ranged download's directory is the resume directory
+	if f.dir != "" && f.dir == f.resumeDir() {
+		rmErr = f.removeResume()
+	}
+
+	if err == nil {
+		err = rmErr
+	}
+
+	return err
+}
+
+// GracefulClose closes the File flushing the downloaded file(s) to disk but, unlike Close,
+// keeps them so that the next download of the same url resumes from them. It is intended
+// to be called when exiting early, eg. on SIGINT; to stop a download still in progress
+// cancel the context passed to OpenContext which also keeps what was downloaded.
+func (f *File) GracefulClose() error {
+
+	var err error
+
+	for _, name := range f.TempFiles() {
+
+		fh, openErr := os.Open(name)
+		if openErr != nil {
+			if err == nil {
+				err = openErr
+			}
+			continue
+		}
+
+		if syncErr := fh.Sync(); syncErr != nil && err == nil {
+			err = syncErr
+		}
+
+		fh.Close()
+	}
+
+	f.closeFileHandles()
+
+	// single stream downloads are only resumed from the resume directory
+	if f.dir != "" && f.dir != f.resumeDir() && len(f.readers) == 1 {
+		if r, ok := f.readers[0].(namer); ok {
+			f.savePartial(r.Name())
+		}
+	}
+
+	f.modTime = defaultTime
+
+	return err
+}
+
+// reconcile checks that the downloaded file(s) add up to the expected size
+func (f *File) reconcile() error {
+
+	// nothing was downloaded by a lazily opened File that was never read, or failed
+	if f.modTime.IsZero() || f.lazy != nil && f.lazy.err != nil {
+		return nil
+	}
+
+	var total int64
+
+	for i := 0; i < len(f.readers); i++ {
+
+		n, err := f.readers[i].Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+
+		total += n
+	}
+
+	expected := f.size
+
+	// only the ranges of the file were downloaded
+	if f.sparse {
+
+		expected = 0
+
+		for _, c := range f.chunks {
+			expected += (c.end - c.start) + 1
+		}
+	}
+
+	if total == expected {
+		return nil
+	}
+
+	err := &ShortDownload{expected: expected, got: total}
+
+	if f.options.VerifyOnClose {
+		return err
+	}
+
+	f.logf("warning: %s\n", err)
+
+	return nil
+}
+
+// Read reads up to len(b) bytes from the File(s). It returns the number of bytes read and any error encountered.
+func (f *File) Read(b []byte) (int, error) {
+
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+
+	n, err := f.Reader.Read(b)
+	f.pos += int64(n)
+
+	return n, err
+}
+
+// WriteTo writes the File(s) from the current read position to w, streaming each chunk in turn
+// using a pooled buffer, until EOF or an error. It returns the number of bytes written and the
+// first error encountered, if any. io.Copy uses WriteTo when copying from the File.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+
+	n, err := f.copy(w, f.Reader)
+	f.pos += n
+
+	return n, err
+}
+
+// ReadAt reads len(b) bytes from the File(s) starting at byte offset off. It returns the number of bytes
+// read and the error, if any. ReadAt always returns a non-nil error when n < len(b). At end of file, that
+// error is io.EOF. ReadAt does not affect the read position used by Read.
+func (f *File) ReadAt(b []byte, off int64) (n int, err error) {
+
+	if off < 0 {
+		return 0, errors.New("download.File.ReadAt: negative offset")
+	}
+
+	if err = f.load(); err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < len(f.chunks) && n < len(b); i++ {
+
+		c := f.chunks[i]
+		pos := off + int64(n)
+
+		if pos > c.end {
+			continue
+		}
+
+		// a gap between the ranges downloaded by OpenRanges
+		if pos < c.start {
+
+			if !f.options.ZeroFillGaps {
+				return n, &RangeNotDownloaded{start: pos, end: c.start - 1}
+			}
+
+			gap := len(b) - n
+			if c.start-pos < int64(gap) {
+				gap = int(c.start - pos)
+			}
+
+			for j := n; j < n+gap; j++ {
+				b[j] = 0
+			}
+
+			n += gap
+			pos += int64(gap)
+
+			if n == len(b) {
+				break
+			}
+		}
+
+		want := len(b) - n
+		if remaining := c.end - pos + 1; remaining < int64(want) {
+			want = int(remaining)
+		}
+
+		var m int
+
+		m, err = f.readers[i].ReadAt(b[n:n+want], pos-c.start)
+		n += m
+
+		if m < want {
+			return
+		}
+	}
+
+	if n < len(b) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Seek sets the offset for the next Read on the File(s) to offset, interpreted according to whence:
+// io.SeekStart means relative to the start of the file, io.SeekCurrent means relative to the current
+// offset, and io.SeekEnd means relative to the end. It returns the new offset and an error, if any.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("download.File.Seek: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("download.File.Seek: negative position")
+	}
+
+	readers := make([]io.Reader, 0, len(f.readers))
+
+	for i := 0; i < len(f.chunks); i++ {
+
+		c := f.chunks[i]
+
+		if offset > c.end {
+			continue
+		}
+
+		pos := offset - c.start
+		if pos < 0 {
+			pos = 0
+		}
+
+		if _, err := f.readers[i].Seek(pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+
+		readers = append(readers, f.readers[i])
+	}
+
+	f.Reader = io.MultiReader(readers...)
+	f.pos = offset
+
+	return offset, nil
+}
+
+// ReadSeekCloser returns an io.ReadSeekCloser view of the File(s) along with it's size. The view also
+// implements io.ReaderAt, so can be passed directly to readers such as archive/zip.NewReader, and has
+// it's own read position independent of the File. Closing the view closes the File.
+func (f *File) ReadSeekCloser() (io.ReadSeekCloser, int64, error) {
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &readSeekCloser{SectionReader: io.NewSectionReader(f, 0, fi.Size()), f: f}, fi.Size(), nil
+}
+
+// readSeekCloser is a view of a File with it's own read position
+type readSeekCloser struct {
+	*io.SectionReader
+	f *File
+}
+
+// Close closes the underlying File
+func (r *readSeekCloser) Close() error {
+	return r.f.Close()
+}
+
+// TempFiles returns the paths of the temporary file(s) currently backing the download,
+// all of which are removed by Close.
+func (f *File) TempFiles() []string {
+
+	files := make([]string, 0, len(f.readers))
+
+	for i := 0; i < len(f.readers); i++ {
+		if fh, ok := f.readers[i].(namer); ok {
+			files = append(files, fh.Name())
+		}
+	}
+
+	return files
+}
+
+func (f *File) closeFileHandles() {
+	for i := 0; i < len(f.readers); i++ {
+		if f.readers[i] != nil { // possible if cancelled or error occured
+			f.readers[i].Close()
+		}
+	}
+}
+
+// client returns the http.Client to use for the download requests, http.DefaultTransport
+// is used unless the Options configure the transport
+func (f *File) client() http.Client {
+
+	if f.options.Client != nil {
+		return f.options.Client()
+	}
+
+	return http.Client{Transport: f.transportConfig().transport()}
+}
+
+// do sends the http request using the download client, auditing it when requested
+func (f *File) do(req *http.Request) (*http.Response, error) {
+
+	for k, v := range f.options.Headers {
+
+		k = http.CanonicalHeaderKey(k)
+
+		// the range is determined by the download, not the caller
+		if k == "Range" {
+			continue
+		}
+
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = append([]string(nil), v...)
+		}
+	}
+
+	ranged := req.Header.Get("Range") != ""
+
+	if ranged {
+		// ranges are of the raw bytes, a compressed response, whether transparently
+		// decompressed or not, would corrupt the download
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+
+	client := f.client()
+
+	resp, err := client.Do(req)
+
+	if f.options.Audit != nil {
+
+		record := RequestRecord{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Range:  req.Header.Get("Range"),
+		}
+
+		if resp != nil {
+			record.Status = resp.StatusCode
+		}
+
+		f.options.Audit(record)
+	}
+
+	return resp, err
+}
+
+// resumeDir returns the directory used to store the download for resuming
+func (f *File) resumeDir() string {
+	return filepath.Join(f.options.tempDir(), defaultDir+f.generateHash())
+}
+
+func (f *File) generateHash() string {
+
+	// Open to a better way, but should not collide
+	h := sha1.New()
+	io.WriteString(h, f.url)
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// chunks up downloads into 2MB chunks, when Accept-Ranges supported
+func defaultConcurrencyFn(length int64) int {
+	return defaultGoroutines
+}
+
+func defaultBackoffFn(attempt int) time.Duration {
+
+	// doubling overflows after enough attempts
+	if shift := uint(attempt - 1); shift < 63 && defaultRetryBackoff <= math.MaxInt64>>shift {
+		return defaultRetryBackoff << shift
+	}
+
+	return math.MaxInt64
 }
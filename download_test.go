@@ -1,16 +1,27 @@
 package download
 
 import (
+	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	neturl "net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -167,11 +178,21 @@ func TestBadOptions(t *testing.T) {
 
 	url = server.URL + "/testdata/bad-content-length"
 
-	expected = "Invalid content length '-1'"
+	// without a length the file is downloaded in a single stream
+	f, err := Open(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	_, err = Open(url, nil)
-	if err == nil || err.Error() != expected {
-		t.Fatalf("Expected '%s' got '%s'", expected, err)
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, _ := ioutil.ReadFile(data); !bytes.Equal(b, expected) {
+		t.Fatal("Downloaded content does not match")
 	}
 
 	url = server.URL + "/testdata/good-head-bad-partial"
@@ -179,8 +200,10 @@ func TestBadOptions(t *testing.T) {
 	expected = "Invalid response code, received '404' expected '206'"
 
 	_, err = Open(url, nil)
-	if err == nil || err.Error() != expected {
-		t.Fatalf("Expected '%s' got '%s'", expected, err)
+
+	var chunkErr *ChunkError
+	if !errors.As(err, &chunkErr) || chunkErr.Unwrap().Error() != expected {
+		t.Fatalf("Expected chunk error '%s' got '%s'", expected, err)
 	}
 
 	url = server.URL + "/testdata/data.txt"
@@ -190,7 +213,7 @@ func TestBadOptions(t *testing.T) {
 		},
 	}
 
-	f, err := Open(url, options)
+	f, err = Open(url, options)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -465,3 +488,3911 @@ func TestContextTimeout(t *testing.T) {
 		t.Fatalf("Expected prefix '%s' and suffix '%s' but got '%s'", prefix, suffix, err.Error())
 	}
 }
+
+func TestDownloadRangeTinyFile(t *testing.T) {
+
+	content := []byte("tiny file")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/tiny.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "tiny.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/tiny.txt"
+
+	options := &Options{
+		Concurrency: func(size int64) int {
+			return 30
+		},
+		MinSizeForRanges: -1,
+	}
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatalf("Expected '%s' got '%s'", content, b)
+	}
+}
+
+func TestDisableKeepAlives(t *testing.T) {
+
+	var m sync.Mutex
+	var conns int
+
+	fs := http.StripPrefix("/testdata/", http.FileServer(http.Dir("./testdata")))
+
+	mux := http.NewServeMux()
+	mux.Handle("/testdata/", fs)
+
+	server := httptest.NewUnstartedServer(mux)
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			m.Lock()
+			conns++
+			m.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	url := server.URL + "/testdata/data.txt"
+
+	options := &Options{
+		Concurrency: func(size int64) int {
+			return 1
+		},
+		DisableKeepAlives: true,
+		IdleConnTimeout:   time.Second,
+	}
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m.Lock()
+	defer m.Unlock()
+
+	// one for the HEAD request and one for the single partial download
+	if conns != 2 {
+		t.Fatalf("Expected '%d' connections got '%d'", 2, conns)
+	}
+}
+
+func TestReverseOrder(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 10)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/reverse.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "reverse.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/reverse.txt"
+	dir := filepath.Join(os.TempDir(), defaultDir+(&File{url: url}).generateHash())
+
+	var m sync.Mutex
+	var lastFirst bool
+
+	options := &Options{
+		Concurrency: func(size int64) int {
+			return 5
+		},
+		Request: func(r *http.Request) {
+
+			if r.Header.Get("Range") != "bytes=0-19" {
+				return
+			}
+
+			// the last chunk must already be downloading when the first is requested
+			_, err := os.Stat(filepath.Join(dir, "4"))
+
+			m.Lock()
+			lastFirst = err == nil
+			m.Unlock()
+		},
+		ReverseOrder:     true,
+		MinSizeForRanges: -1,
+	}
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m.Lock()
+	if !lastFirst {
+		t.Fatal("Expected last chunk to be scheduled before the first")
+	}
+	m.Unlock()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatalf("Expected '%s' got '%s'", content, b)
+	}
+}
+
+// assertRemoved fails the test if any of the files still exist
+func assertRemoved(t *testing.T, files []string) {
+
+	for _, file := range files {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Fatalf("Expected temp file '%s' to be removed", file)
+		}
+	}
+}
+
+func TestTempFiles(t *testing.T) {
+
+	fs := http.StripPrefix("/testdata/", http.FileServer(http.Dir("./testdata")))
+
+	mux := http.NewServeMux()
+	mux.Handle("/testdata/", fs)
+	mux.HandleFunc("/testdata/no-range", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		f, _ := os.Open(data)
+		defer f.Close()
+
+		io.Copy(w, f)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		url   string
+		files int
+	}{
+		{url: server.URL + "/testdata/data.txt", files: defaultGoroutines},
+		{url: server.URL + "/testdata/no-range", files: 1},
+	}
+
+	for _, tt := range tests {
+
+		f, err := Open(tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := f.TempFiles()
+		if len(files) != tt.files {
+			t.Fatalf("Expected '%d' temp files got '%d'", tt.files, len(files))
+		}
+
+		for _, file := range files {
+			if _, err := os.Stat(file); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err = f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		assertRemoved(t, files)
+	}
+}
+
+func TestResumeSingleStream(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	half := len(content) / 2
+
+	var m sync.Mutex
+	var interrupted bool
+	var rangeHeader string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/resume.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		m.Lock()
+		defer m.Unlock()
+
+		if !interrupted {
+
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			// send half the content and drop the connection
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:half])
+			w.(http.Flusher).Flush()
+			interrupted = true
+			return
+		}
+
+		if r.Method == http.MethodGet {
+			rangeHeader = r.Header.Get("Range")
+		}
+
+		http.ServeContent(w, r, "resume.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/resume.txt"
+
+	_, err := Open(url, nil)
+	if err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+
+	f, err := Open(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m.Lock()
+	expected := "bytes=" + strconv.Itoa(half) + "-"
+	if rangeHeader != expected {
+		t.Fatalf("Expected '%s' got '%s'", expected, rangeHeader)
+	}
+	m.Unlock()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Resumed content does not match")
+	}
+}
+
+func TestMinSizeForRanges(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var ranges int
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/small.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+			m.Lock()
+			ranges++
+			m.Unlock()
+		}
+
+		http.ServeContent(w, r, "small.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/small.txt"
+
+	tests := []struct {
+		minSize int64
+		ranges  int
+	}{
+		{minSize: 0, ranges: 0},
+		{minSize: int64(len(content)) + 1, ranges: 0},
+		{minSize: int64(len(content)), ranges: defaultGoroutines},
+		{minSize: -1, ranges: defaultGoroutines},
+	}
+
+	for _, tt := range tests {
+
+		m.Lock()
+		ranges = 0
+		m.Unlock()
+
+		f, err := Open(url, &Options{MinSizeForRanges: tt.minSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		m.Lock()
+		if ranges != tt.ranges {
+			t.Fatalf("Expected '%d' ranged requests got '%d'", tt.ranges, ranges)
+		}
+		m.Unlock()
+	}
+}
+
+func TestQueryModifier(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/signed.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if rng := r.Header.Get("Range"); rng != "" && "bytes="+r.URL.Query().Get("sig") != rng {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		http.ServeContent(w, r, "signed.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/signed.txt"
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		QueryModifier: func(q neturl.Values, idx int, start, end int64) neturl.Values {
+			q.Set("sig", strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10))
+			return q
+		},
+	}
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
+
+func TestReadSeekCloser(t *testing.T) {
+
+	var buf bytes.Buffer
+
+	zw := zip.NewWriter(&buf)
+
+	for i := 0; i < 5; i++ {
+
+		w, err := zw.Create("file" + strconv.Itoa(i) + ".txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write(bytes.Repeat([]byte(strconv.Itoa(i)), 1000))
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content := buf.Bytes()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/archive.zip", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "archive.zip", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/archive.zip"
+
+	for _, minSize := range []int64{0, -1} {
+
+		f, err := Open(url, &Options{MinSizeForRanges: minSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsc, size, err := f.ReadSeekCloser()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if size != int64(len(content)) {
+			t.Fatalf("Expected size '%d' got '%d'", len(content), size)
+		}
+
+		zr, err := zip.NewReader(rsc.(io.ReaderAt), size)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(zr.File) != 5 {
+			t.Fatalf("Expected '%d' zip entries got '%d'", 5, len(zr.File))
+		}
+
+		for i, zf := range zr.File {
+
+			r, err := zf.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := ioutil.ReadAll(r)
+			r.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, bytes.Repeat([]byte(strconv.Itoa(i)), 1000)) {
+				t.Fatalf("Entry '%s' content does not match", zf.Name)
+			}
+		}
+
+		// the view has it's own position independent of the File
+		b, err := ioutil.ReadAll(rsc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("View content does not match")
+		}
+
+		b, err = ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("File content does not match")
+		}
+
+		if err = rsc.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = f.Stat(); err == nil {
+			t.Fatal("Expected closing the view to close the File")
+		}
+	}
+}
+
+func TestSeekReadAt(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/seek.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "seek.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/seek.txt"
+
+	for _, minSize := range []int64{0, -1} {
+
+		f, err := Open(url, &Options{MinSizeForRanges: minSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := make([]byte, 250)
+
+		n, err := f.ReadAt(b, 95)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != len(b) || !bytes.Equal(b, content[95:345]) {
+			t.Fatal("ReadAt content does not match")
+		}
+
+		n, err = f.ReadAt(b, 900)
+		if err != io.EOF {
+			t.Fatalf("Expected '%v' got '%v'", io.EOF, err)
+		}
+
+		if n != 100 || !bytes.Equal(b[:n], content[900:]) {
+			t.Fatal("ReadAt content does not match")
+		}
+
+		tests := []struct {
+			offset   int64
+			whence   int
+			expected int64
+		}{
+			{offset: 150, whence: io.SeekStart, expected: 150},
+			{offset: 10, whence: io.SeekCurrent, expected: 160 + 10},
+			{offset: -5, whence: io.SeekEnd, expected: 995},
+		}
+
+		for _, tt := range tests {
+
+			pos, err := f.Seek(tt.offset, tt.whence)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if pos != tt.expected {
+				t.Fatalf("Expected position '%d' got '%d'", tt.expected, pos)
+			}
+
+			n, err := io.ReadFull(f, b[:10])
+			if err != nil && err != io.ErrUnexpectedEOF {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b[:n], content[pos:pos+int64(n)]) {
+				t.Fatalf("Content at position '%d' does not match", pos)
+			}
+		}
+
+		if _, err = f.Seek(-1, io.SeekStart); err == nil {
+			t.Fatal("Expected error got <nil>")
+		}
+
+		f.Close()
+	}
+}
+
+func TestIgnoreHeadLength(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var ranges []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/lying-head.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)*2))
+			return
+		}
+
+		m.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		m.Unlock()
+
+		http.ServeContent(w, r, "lying-head.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/lying-head.txt"
+
+	options := &Options{
+		Concurrency: func(size int64) int {
+			return 4
+		},
+		MinSizeForRanges: -1,
+		IgnoreHeadLength: true,
+	}
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Size() != int64(len(content)) {
+		t.Fatalf("Expected size '%d' got '%d'", len(content), fi.Size())
+	}
+
+	m.Lock()
+	sort.Strings(ranges)
+	expected := []string{"bytes=0-0", "bytes=0-249", "bytes=250-499", "bytes=500-749", "bytes=750-999"}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("Expected '%v' got '%v'", expected, ranges)
+	}
+	m.Unlock()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
+
+func TestChunkedRangeable(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// advertises ranges but never sends the length, nor honours a range
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.WriteHeader(http.StatusOK)
+
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		for i := 0; i < len(content); i += 1000 {
+			w.Write(content[i : i+1000])
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/chunked.txt"
+
+	var reason string
+
+	f, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		OnFallback: func(r string) {
+			reason = r
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the size is known once downloaded
+	if fi.Size() != int64(len(content)) {
+		t.Fatalf("Expected size '%d' got '%d'", len(content), fi.Size())
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	if expected := "size '-1' can't be split into ranges"; reason != expected {
+		t.Fatalf("Expected fallback reason '%s' got '%s'", expected, reason)
+	}
+
+}
+
+func TestParseContentRange(t *testing.T) {
+
+	tests := []struct {
+		value string
+		start int64
+		end   int64
+		total int64
+		err   bool
+	}{
+		{value: "bytes 0-0/100", start: 0, end: 0, total: 100},
+		{value: "bytes 250-499/1000", start: 250, end: 499, total: 1000},
+		{value: "bytes 0-99/*", start: 0, end: 99, total: -1},
+		{value: "", err: true},
+		{value: "bytes */1000", err: true},
+		{value: "items 0-1/2", err: true},
+		{value: "bytes a-1/2", err: true},
+		{value: "bytes 0-b/2", err: true},
+		{value: "bytes 0-1/c", err: true},
+	}
+
+	for _, tt := range tests {
+
+		start, end, total, err := parseContentRange(tt.value)
+
+		if tt.err {
+			if err == nil {
+				t.Fatalf("Expected error for '%s' got <nil>", tt.value)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if start != tt.start || end != tt.end || total != tt.total {
+			t.Fatalf("Expected '%d-%d/%d' got '%d-%d/%d'", tt.start, tt.end, tt.total, start, end, total)
+		}
+	}
+}
+
+func TestHeadMissingLength(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var ranges int
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/no-head-length.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Accept-Ranges", "bytes")
+			return
+		}
+
+		m.Lock()
+		ranges++
+		m.Unlock()
+
+		http.ServeContent(w, r, "no-head-length.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/no-head-length.txt"
+
+	f, err := Open(url, &Options{MinSizeForRanges: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m.Lock()
+	// the probe plus each chunk
+	if ranges != defaultGoroutines+1 {
+		t.Fatalf("Expected '%d' ranged requests got '%d'", defaultGoroutines+1, ranges)
+	}
+	m.Unlock()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
+
+func TestChunkLaunchRate(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var launches []time.Time
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/staggered.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+			m.Lock()
+			launches = append(launches, time.Now())
+			m.Unlock()
+		}
+
+		http.ServeContent(w, r, "staggered.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/staggered.txt"
+
+	options := &Options{
+		Concurrency: func(size int64) int {
+			return 4
+		},
+		MinSizeForRanges: -1,
+		ChunkLaunchRate:  20,
+	}
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	m.Lock()
+	if len(launches) != 4 {
+		t.Fatalf("Expected '%d' chunk launches got '%d'", 4, len(launches))
+	}
+
+	// 3 intervals of 50ms
+	if elapsed := launches[3].Sub(launches[0]); elapsed < 140*time.Millisecond {
+		t.Fatalf("Expected chunk launches to be spread over at least 140ms got '%s'", elapsed)
+	}
+	m.Unlock()
+
+	// cancel while staggering
+	options.ChunkLaunchRate = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err = OpenContext(ctx, url, options)
+	if _, ok := err.(*DeadlineExceeded); !ok {
+		t.Fatalf("Expected error to be of type *DeadlineExceeded got '%v'", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected staggering to stop on cancellation, took '%s'", elapsed)
+	}
+}
+
+func TestMaxParallel(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	var inFlight, maxInFlight, requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+
+			atomic.AddInt32(&requests, 1)
+
+			for {
+				max := atomic.LoadInt32(&maxInFlight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		http.ServeContent(w, r, "parallel.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/parallel.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	f, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		MaxParallel:      3,
+		Concurrency: func(size int64) int {
+			return 20
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 20 {
+		t.Fatalf("Expected '20' chunk requests got '%d'", n)
+	}
+
+	if n := atomic.LoadInt32(&maxInFlight); n > 3 {
+		t.Fatalf("Expected at most '3' chunk requests in flight got '%d'", n)
+	}
+}
+
+func TestMaxElapsed(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// the chunks persistently fail
+		if r.Header.Get("Range") != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		http.ServeContent(w, r, "elapsed.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/elapsed.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+	defer os.RemoveAll((&File{url: url}).resumeDir())
+
+	start := time.Now()
+
+	_, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		MaxRetries:       1000,
+		MaxElapsed:       200 * time.Millisecond,
+		RetryBackoff: func(attempt int) time.Duration {
+			return 20 * time.Millisecond
+		},
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected Open to give up after about '200ms' took '%s'", elapsed)
+	}
+
+	if _, ok := err.(*DeadlineExceeded); !ok {
+		t.Fatalf("Expected error to be of type *DeadlineExceeded got '%v'", err)
+	}
+
+	var code *InvalidResponseCode
+	if !errors.As(err, &code) {
+		t.Fatalf("Expected the last error to be of type *InvalidResponseCode got '%v'", errors.Unwrap(err))
+	}
+}
+
+func TestOnChunkFile(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "replicated.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/replicated.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	var m sync.Mutex
+	replicated := make(map[int][]byte)
+
+	f, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		Coalesce:         true, // removes the chunk files once downloaded
+		Concurrency: func(size int64) int {
+			return 4
+		},
+		OnChunkFile: func(idx int, path string) {
+
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Error(err)
+			}
+
+			m.Lock()
+			defer m.Unlock()
+
+			if _, ok := replicated[idx]; ok {
+				t.Errorf("Expected chunk '%d' to be reported once", idx)
+			}
+
+			replicated[idx] = b
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if len(replicated) != 4 {
+		t.Fatalf("Expected '4' chunk files got '%d'", len(replicated))
+	}
+
+	for idx, c := range equalChunks(int64(len(content)), 4) {
+		if !bytes.Equal(replicated[idx], content[c.start:c.end+1]) {
+			t.Fatalf("Chunk '%d' content does not match", idx)
+		}
+	}
+}
+
+func TestDrainOnError(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 25600)
+	sent := new(int64)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/failing-chunk.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+
+		var start, end int
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+
+		if start == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+
+		// slowly stream the chunk until complete or the client goes away
+		for pos := start; pos <= end; pos += 1024 {
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Millisecond * 5):
+			}
+
+			n := 1024
+			if pos+n > end+1 {
+				n = end + 1 - pos
+			}
+
+			if _, err := w.Write(content[pos : pos+n]); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+
+			atomic.AddInt64(sent, int64(n))
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/failing-chunk.txt"
+	dir := (&File{url: url}).resumeDir()
+	defer os.RemoveAll(dir)
+
+	options := &Options{
+		Concurrency: func(size int64) int {
+			return 4
+		},
+		MinSizeForRanges: -1,
+	}
+
+	var invalid *InvalidResponseCode
+
+	_, err := Open(url, options)
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected error to be of type *InvalidResponseCode got '%v'", err)
+	}
+
+	// chunks are cancelled long before they complete
+	if n := atomic.LoadInt64(sent); n > int64(len(content))/4 {
+		t.Fatalf("Expected less than '%d' bytes to be sent got '%d'", len(content)/4, n)
+	}
+
+	// start over rather than resuming the partial chunks
+	os.RemoveAll(dir)
+	atomic.StoreInt64(sent, 0)
+	options.DrainOnError = true
+
+	_, err = Open(url, options)
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected error to be of type *InvalidResponseCode got '%v'", err)
+	}
+
+	// the 3 remaining chunks are downloaded in full
+	if n := atomic.LoadInt64(sent); n != int64(len(content))*3/4 {
+		t.Fatalf("Expected '%d' bytes to be sent got '%d'", len(content)*3/4, n)
+	}
+}
+
+func TestRangeMismatch(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/shifted.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		// off by one for the second chunk
+		if r.Header.Get("Range") == "bytes=250-499" {
+			r.Header.Set("Range", "bytes=251-500")
+		}
+
+		http.ServeContent(w, r, "shifted.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/shifted.txt"
+	defer os.RemoveAll((&File{url: url}).resumeDir())
+
+	options := &Options{
+		Concurrency: func(size int64) int {
+			return 4
+		},
+		MinSizeForRanges: -1,
+	}
+
+	var mismatch *RangeMismatch
+
+	_, err := Open(url, options)
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected error to be of type *RangeMismatch got '%v'", err)
+	}
+
+	expected := "Chunk '1' bytes '250-499' failed: Invalid Content-Range, received 'bytes 251-500' expected 'bytes 250-499'"
+
+	if err.Error() != expected {
+		t.Fatalf("Expected '%s' got '%s'", expected, err.Error())
+	}
+}
+
+func TestEqualChunks(t *testing.T) {
+
+	tests := []struct {
+		size      int64
+		n         int
+		remainder int64
+	}{
+		{size: 1, n: 1},
+		{size: 3, n: 3},
+		{size: 10, n: 5},
+		{size: 19, n: 10, remainder: 9},
+		{size: 47, n: 10, remainder: 7},
+		{size: 1000, n: 4},
+		{size: 1001, n: 4, remainder: 1},
+		{size: 1372, n: 10, remainder: 2},
+		{size: 1e8 + 7, n: 10, remainder: 7},
+		{size: 1e6, n: 2000},
+		{size: 1999, n: 1000, remainder: 999},
+	}
+
+	for _, tt := range tests {
+
+		chunks := equalChunks(tt.size, tt.n)
+
+		if len(chunks) != tt.n {
+			t.Fatalf("Expected '%d' chunks got '%d'", tt.n, len(chunks))
+		}
+
+		var pos int64
+		expected := tt.size / int64(tt.n)
+
+		for i, c := range chunks {
+
+			if c.start != pos {
+				t.Fatalf("size '%d' n '%d': chunk '%d' starts at '%d' expected '%d'", tt.size, tt.n, i, c.start, pos)
+			}
+
+			size := c.end - c.start + 1
+
+			if i == tt.n-1 {
+				expected += tt.remainder
+			}
+
+			if size != expected {
+				t.Fatalf("size '%d' n '%d': chunk '%d' has size '%d' expected '%d'", tt.size, tt.n, i, size, expected)
+			}
+
+			pos = c.end + 1
+		}
+
+		if pos != tt.size {
+			t.Fatalf("size '%d' n '%d': chunks cover '%d' bytes", tt.size, tt.n, pos)
+		}
+	}
+
+	// every combination tiles the file exactly
+	for size := int64(1); size <= 200; size++ {
+		for n := 1; int64(n) <= size; n++ {
+
+			var pos int64
+
+			for i, c := range equalChunks(size, n) {
+
+				if c.start != pos || c.end < c.start {
+					t.Fatalf("size '%d' n '%d': chunk '%d' is '%d-%d' expected to start at '%d'", size, n, i, c.start, c.end, pos)
+				}
+
+				pos = c.end + 1
+			}
+
+			if pos != size {
+				t.Fatalf("size '%d' n '%d': chunks cover '%d' bytes", size, n, pos)
+			}
+		}
+	}
+}
+
+func TestExponentialChunks(t *testing.T) {
+
+	tests := []struct {
+		size int64
+		n    int
+	}{
+		{size: 1, n: 1},
+		{size: 5, n: 5},
+		{size: 10, n: 5},
+		{size: 1000, n: 4},
+		{size: 1e8, n: 10},
+		{size: 1e8, n: 30},
+		{size: 1e6, n: 2000},
+	}
+
+	for _, tt := range tests {
+
+		chunks := exponentialChunks(tt.size, tt.n)
+
+		if len(chunks) != tt.n {
+			t.Fatalf("Expected '%d' chunks got '%d'", tt.n, len(chunks))
+		}
+
+		var pos, prev int64
+
+		for i, c := range chunks {
+
+			if c.start != pos {
+				t.Fatalf("size '%d' n '%d': chunk '%d' starts at '%d' expected '%d'", tt.size, tt.n, i, c.start, pos)
+			}
+
+			size := c.end - c.start + 1
+
+			if size < 1 || size < prev {
+				t.Fatalf("size '%d' n '%d': chunk '%d' has size '%d' previous '%d'", tt.size, tt.n, i, size, prev)
+			}
+
+			prev = size
+			pos = c.end + 1
+		}
+
+		if pos != tt.size {
+			t.Fatalf("size '%d' n '%d': chunks cover '%d' bytes", tt.size, tt.n, pos)
+		}
+	}
+
+	// sizes double when there's enough bytes
+	chunks := exponentialChunks(1e8, 10)
+
+	for i := 1; i < len(chunks); i++ {
+
+		prev := chunks[i-1].end - chunks[i-1].start + 1
+		size := chunks[i].end - chunks[i].start + 1
+
+		if size < prev*2-2 || size > prev*2+2 {
+			t.Fatalf("Expected chunk '%d' size '%d' to be double the previous '%d'", i, size, prev)
+		}
+	}
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/exponential.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "exponential.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	options := &Options{
+		MinSizeForRanges:  -1,
+		ExponentialChunks: true,
+	}
+
+	f, err := Open(server.URL+"/testdata/exponential.txt", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
+
+func TestOnFallback(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	var m sync.Mutex
+	var interrupted bool
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/ranges.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "ranges.txt", time.Time{}, bytes.NewReader(content))
+	})
+	mux.HandleFunc("/testdata/bad-head.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Write(content)
+	})
+	mux.HandleFunc("/testdata/no-ranges.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	})
+	mux.HandleFunc("/testdata/interrupted.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		m.Lock()
+		defer m.Unlock()
+
+		if !interrupted && r.Method == http.MethodGet {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write(content[:100])
+			interrupted = true
+			return
+		}
+
+		if !interrupted {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		http.ServeContent(w, r, "interrupted.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var reasons []string
+
+	options := &Options{
+		OnFallback: func(reason string) {
+			reasons = append(reasons, reason)
+		},
+	}
+
+	// interrupt the first attempt to leave a partial download
+	if _, err := Open(server.URL+"/testdata/interrupted.txt", options); err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+
+	tests := []struct {
+		url      string
+		minSize  int64
+		expected string
+	}{
+		{url: "/testdata/ranges.txt", minSize: -1, expected: ""},
+		{url: "/testdata/ranges.txt", expected: "size '10000' below MinSizeForRanges '1048576'"},
+		{url: "/testdata/bad-head.txt", expected: "unexpected HEAD response code '405'"},
+		{url: "/testdata/no-ranges.txt", expected: "server does not support ranges"},
+		{url: "/testdata/interrupted.txt", minSize: -1, expected: "resuming interrupted single stream download"},
+	}
+
+	for _, tt := range tests {
+
+		reasons = nil
+		options.MinSizeForRanges = tt.minSize
+
+		f, err := Open(server.URL+tt.url, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		var expected []string
+		if tt.expected != "" {
+			expected = []string{tt.expected}
+		}
+
+		if !reflect.DeepEqual(reasons, expected) {
+			t.Fatalf("Expected fallback reasons '%v' got '%v'", expected, reasons)
+		}
+	}
+}
+
+func TestRangeNotSupported(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	// advertises ranges in the HEAD but always sends the whole file
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/ignored-ranges.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	_, err := Open(url, &Options{MinSizeForRanges: -1, MaxRetries: 2})
+
+	var rangeErr *RangeNotSupported
+	if !errors.As(err, &rangeErr) {
+		t.Fatalf("Expected error to be of type *RangeNotSupported got '%v'", err)
+	}
+
+	expected := "Range request ignored by server for '" + url + "'"
+	if rangeErr.Error() != expected {
+		t.Fatalf("Expected '%s' got '%s'", expected, rangeErr.Error())
+	}
+
+	for _, inMemory := range []bool{false, true} {
+
+		var reasons []string
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges:       -1,
+			InMemory:               inMemory,
+			FallbackOnIgnoredRange: true,
+			OnFallback: func(reason string) {
+				reasons = append(reasons, reason)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		if len(reasons) != 1 || reasons[0] != "server ignored the ranged request" {
+			t.Fatalf("Expected fallback reason 'server ignored the ranged request' got '%v'", reasons)
+		}
+	}
+
+	if _, err = os.Stat((&File{url: url}).resumeDir()); !os.IsNotExist(err) {
+		t.Fatalf("Expected resume directory to be removed got '%v'", err)
+	}
+}
+
+func TestVerifyOnClose(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/truncated.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "truncated.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/truncated.txt"
+
+	for _, verify := range []bool{false, true} {
+
+		options := &Options{
+			MinSizeForRanges: -1,
+			VerifyOnClose:    verify,
+		}
+
+		f, err := Open(url, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := f.TempFiles()
+
+		if err = os.Truncate(files[1], 10); err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.Close()
+
+		assertRemoved(t, files)
+
+		if !verify {
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			continue
+		}
+
+		if _, ok := err.(*ShortDownload); !ok {
+			t.Fatalf("Expected error to be of type *ShortDownload got '%v'", err)
+		}
+
+		expected := "Short download, received '910' bytes expected '1000'"
+
+		if err.Error() != expected {
+			t.Fatalf("Expected '%s' got '%s'", expected, err.Error())
+		}
+	}
+
+	// complete downloads close without error
+	f, err := Open(url, &Options{MinSizeForRanges: -1, VerifyOnClose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenSize(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var requests []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/sized.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		m.Lock()
+		requests = append(requests, r.Method+" "+r.Header.Get("Range"))
+		m.Unlock()
+
+		http.ServeContent(w, r, "sized.txt", time.Time{}, bytes.NewReader(content))
+	})
+	mux.HandleFunc("/testdata/sized-no-ranges.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		m.Lock()
+		requests = append(requests, r.Method+" "+r.Header.Get("Range"))
+		m.Unlock()
+
+		w.Write(content)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	options := &Options{
+		Concurrency: func(size int64) int {
+			return 4
+		},
+		MinSizeForRanges: -1,
+	}
+
+	tests := []struct {
+		url      string
+		expected []string
+	}{
+		{
+			url:      "/testdata/sized.txt",
+			expected: []string{"GET bytes=0-0", "GET bytes=0-249", "GET bytes=250-499", "GET bytes=500-749", "GET bytes=750-999"},
+		},
+		{
+			url:      "/testdata/sized-no-ranges.txt",
+			expected: []string{"GET ", "GET bytes=0-0"},
+		},
+	}
+
+	for _, tt := range tests {
+
+		m.Lock()
+		requests = nil
+		m.Unlock()
+
+		f, err := OpenSize(context.Background(), server.URL+tt.url, int64(len(content)), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		m.Lock()
+		sort.Strings(requests)
+		if !reflect.DeepEqual(requests, tt.expected) {
+			t.Fatalf("Expected requests '%v' got '%v'", tt.expected, requests)
+		}
+		m.Unlock()
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/testdata/relative.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "relative.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		base string
+		url  string
+	}{
+		{base: server.URL + "/api/", url: "testdata/relative.txt"},
+		{base: server.URL + "/other/", url: "/api/testdata/relative.txt"},
+		{base: "http://invalid.invalid/", url: server.URL + "/api/testdata/relative.txt"},
+	}
+
+	for _, tt := range tests {
+
+		f, err := Open(tt.url, &Options{BaseURL: tt.base})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+	}
+
+	if _, err := Open("relative.txt", &Options{BaseURL: "%"}); err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+}
+
+func TestAudit(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "audit.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/audit.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	var mu sync.Mutex
+	var records []RequestRecord
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+		Audit: func(r RequestRecord) {
+			mu.Lock()
+			records = append(records, r)
+			mu.Unlock()
+		},
+	}
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if len(records) != 5 {
+		t.Fatalf("Expected '%d' audit records got '%d'", 5, len(records))
+	}
+
+	if records[0].Method != http.MethodHead || records[0].URL != url || records[0].Range != "" || records[0].Status != http.StatusOK {
+		t.Fatalf("Unexpected HEAD audit record '%+v'", records[0])
+	}
+
+	var ranges []string
+
+	for _, r := range records[1:] {
+
+		if r.Method != http.MethodGet || r.URL != url || r.Status != http.StatusPartialContent {
+			t.Fatalf("Unexpected partial audit record '%+v'", r)
+		}
+
+		ranges = append(ranges, r.Range)
+	}
+
+	sort.Strings(ranges)
+
+	expected := []string{"bytes=0-249", "bytes=250-499", "bytes=500-749", "bytes=750-999"}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("Expected ranges '%v' got '%v'", expected, ranges)
+	}
+}
+
+func TestMaxSizeHeader(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Max-Size", r.URL.Query().Get("max"))
+		http.ServeContent(w, r, "max.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		max     string
+		options *Options
+		err     string
+	}{
+		{max: "500", options: &Options{MaxSizeHeader: "X-Max-Size"}, err: "Max size exceeded, received '1000' bytes expected at most '500'"},
+		{max: "500", options: &Options{MaxSizeHeader: "X-Max-Size", MinSizeForRanges: -1}, err: "Max size exceeded, received '1000' bytes expected at most '500'"},
+		{max: "1000", options: &Options{MaxSizeHeader: "X-Max-Size"}},
+		{max: "500", options: &Options{}},
+	}
+
+	for i, tt := range tests {
+
+		url := server.URL + "/max.txt?max=" + tt.max
+
+		f, err := Open(url, tt.options)
+
+		if tt.err != "" {
+
+			if err == nil {
+				f.Close()
+				t.Fatalf("%d: Expected '%s' got <nil>", i, tt.err)
+			}
+
+			if _, ok := err.(*MaxSizeExceeded); !ok || err.Error() != tt.err {
+				t.Fatalf("%d: Expected '%s' got '%v'", i, tt.err, err)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%d: Downloaded content does not match", i)
+		}
+	}
+}
+
+func TestGracefulClose(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var mu sync.Mutex
+	var ranges []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+		}
+
+		http.ServeContent(w, r, "graceful.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		options  *Options
+		expected []string
+	}{
+		{
+			name: "ranged",
+			options: &Options{
+				MinSizeForRanges: -1,
+				Concurrency: func(size int64) int {
+					return 2
+				},
+			},
+			expected: []string{"bytes=250-499"},
+		},
+		{
+			name:     "single",
+			options:  &Options{},
+			expected: []string{"bytes=250-"},
+		},
+		{
+			name:     "complete",
+			options:  &Options{},
+			expected: []string{"bytes=1000-"},
+		},
+	}
+
+	for _, tt := range tests {
+
+		url := server.URL + "/" + tt.name + "/graceful.txt"
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		f, err := Open(url, tt.options)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		if err = f.GracefulClose(); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		// simulate the download being interrupted part way through the first file
+		switch tt.name {
+		case "ranged":
+			err = os.Truncate(f.TempFiles()[0], 250)
+		case "single":
+			err = os.Truncate(filepath.Join(f.resumeDir(), partialName), 250)
+		}
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		mu.Lock()
+		ranges = nil
+		mu.Unlock()
+
+		f, err = Open(url, tt.options)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%s: Downloaded content does not match", tt.name)
+		}
+
+		if !reflect.DeepEqual(ranges, tt.expected) {
+			t.Fatalf("%s: Expected resumed ranges '%v' got '%v'", tt.name, tt.expected, ranges)
+		}
+	}
+}
+
+func TestAcceptRanges(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			for _, v := range r.URL.Query()["accept"] {
+				w.Header().Add("Accept-Ranges", v)
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+
+		http.ServeContent(w, r, "accept.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		accept    []string
+		rangeable bool
+	}{
+		{accept: []string{"bytes"}, rangeable: true},
+		{accept: []string{"Bytes"}, rangeable: true},
+		{accept: []string{" BYTES "}, rangeable: true},
+		{accept: []string{"none, bytes"}, rangeable: true},
+		{accept: []string{"none", "bytes"}, rangeable: true},
+		{accept: []string{"none"}, rangeable: false},
+		{accept: []string{""}, rangeable: false},
+		{accept: nil, rangeable: false},
+	}
+
+	for i, tt := range tests {
+
+		q := neturl.Values{"accept": tt.accept}
+		url := server.URL + "/accept.txt?" + q.Encode()
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		var fellback bool
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges: -1,
+			OnFallback: func(reason string) {
+				fellback = true
+			},
+		})
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		f.Close()
+
+		if fellback == tt.rangeable {
+			t.Fatalf("%d: Expected rangeable '%t' for Accept-Ranges '%q'", i, tt.rangeable, tt.accept)
+		}
+	}
+}
+
+func TestRangeDecision(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead && r.URL.Query().Get("slow") != "" {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		http.ServeContent(w, r, "decision.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		url    string
+		single bool
+	}{
+		{url: "/decision.txt"},
+		{url: "/decision.txt?slow=1", single: true},
+	}
+
+	for i, tt := range tests {
+
+		url := server.URL + tt.url
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		var fellback bool
+		var size int64
+		var rtt time.Duration
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges: -1,
+			RangeDecision: func(s int64, rttEstimate time.Duration) bool {
+				size, rtt = s, rttEstimate
+				return rttEstimate < 50*time.Millisecond
+			},
+			OnFallback: func(reason string) {
+				fellback = true
+			},
+		})
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%d: Downloaded content does not match", i)
+		}
+
+		if size != int64(len(content)) {
+			t.Fatalf("%d: Expected RangeDecision size '%d' got '%d'", i, len(content), size)
+		}
+
+		if tt.single && rtt < 100*time.Millisecond {
+			t.Fatalf("%d: Expected RangeDecision rtt of at least '%s' got '%s'", i, 100*time.Millisecond, rtt)
+		}
+
+		if fellback != tt.single {
+			t.Fatalf("%d: Expected single stream '%t' got '%t'", i, tt.single, fellback)
+		}
+	}
+}
+
+func TestOutOfOrderCompletion(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var completed []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rng := r.Header.Get("Range")
+
+		// the middle chunk finishes last
+		if rng == "bytes=250-499" {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		http.ServeContent(w, r, "order.txt", time.Time{}, bytes.NewReader(content))
+
+		if r.Method == http.MethodGet {
+			m.Lock()
+			completed = append(completed, rng)
+			m.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	url := server.URL + "/order.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	dir, err := ioutil.TempDir("", "go-download-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m.Lock()
+	if len(completed) != 4 || completed[3] != "bytes=250-499" {
+		t.Fatalf("Expected the middle chunk to complete last got '%v'", completed)
+	}
+	m.Unlock()
+
+	path := filepath.Join(dir, "order.txt")
+
+	if err = f.SaveWithMetadata(path); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Assembled content does not match")
+	}
+}
+
+func TestNoResume(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var ranges int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranges, 1)
+		}
+
+		http.ServeContent(w, r, "noresume.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/noresume.txt"
+	dir := (&File{url: url}).resumeDir()
+
+	// a complete but corrupt first chunk and an unrelated file
+	os.RemoveAll(dir)
+	if err := os.Mkdir(dir, fileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "0"), bytes.Repeat([]byte("x"), 250), fileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := filepath.Join(dir, "stale")
+	if err := ioutil.WriteFile(stale, []byte("stale"), fileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		NoResume:         true,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err = os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("Expected the resume directory to be recreated got '%v'", err)
+	}
+
+	if n := atomic.LoadInt32(&ranges); n != 4 {
+		t.Fatalf("Expected '%d' ranged requests got '%d'", 4, n)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
+
+func TestChunkError(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") == "bytes=500-749" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		http.ServeContent(w, r, "chunk-error.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/chunk-error.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+	defer os.RemoveAll((&File{url: url}).resumeDir())
+
+	_, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	})
+
+	var chunkErr *ChunkError
+	if !errors.As(err, &chunkErr) {
+		t.Fatalf("Expected error to be of type *ChunkError got '%v'", err)
+	}
+
+	if start, end := chunkErr.Range(); chunkErr.Index() != 2 || start != 500 || end != 749 {
+		t.Fatalf("Expected chunk '2' bytes '500-749' got '%d' bytes '%d-%d'", chunkErr.Index(), start, end)
+	}
+
+	var invalid *InvalidResponseCode
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected wrapped error to be of type *InvalidResponseCode got '%v'", chunkErr.Unwrap())
+	}
+
+	expected := "Chunk '2' bytes '500-749' failed: Invalid response code, received '502' expected '206'"
+	if err.Error() != expected {
+		t.Fatalf("Expected '%s' got '%s'", expected, err)
+	}
+}
+
+func TestMaxConnsHeader(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var ranges int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranges, 1)
+		}
+
+		w.Header().Set("X-Max-Connections", "2")
+		http.ServeContent(w, r, "max-conns.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/max-conns.txt"
+
+	tests := []struct {
+		header   string
+		expected int32
+	}{
+		{header: "X-Max-Connections", expected: 2},
+		{header: "", expected: 8},
+	}
+
+	for i, tt := range tests {
+
+		os.RemoveAll((&File{url: url}).resumeDir())
+		atomic.StoreInt32(&ranges, 0)
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges: -1,
+			MaxConnsHeader:   tt.header,
+			Concurrency: func(size int64) int {
+				return 8
+			},
+		})
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%d: Downloaded content does not match", i)
+		}
+
+		if n := atomic.LoadInt32(&ranges); n != tt.expected {
+			t.Fatalf("%d: Expected '%d' chunks got '%d'", i, tt.expected, n)
+		}
+	}
+}
+
+func TestRefreshStat(t *testing.T) {
+
+	var m sync.Mutex
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.URL.Query().Get("slow") != "" {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		m.Lock()
+		b := content
+		m.Unlock()
+
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, len(b)))
+		w.Header().Set("Content-Disposition", `attachment; filename="refreshed.txt"`)
+		http.ServeContent(w, r, "refresh.txt", time.Time{}, bytes.NewReader(b))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/refresh.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	f, err := Open(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m.Lock()
+	content = append(content, content...)
+	m.Unlock()
+
+	fi, err := f.RefreshStat(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Size() != 2000 {
+		t.Fatalf("Expected refreshed size '%d' got '%d'", 2000, fi.Size())
+	}
+
+	if etag := fi.Sys().(http.Header).Get("ETag"); etag != `"2000"` {
+		t.Fatalf("Expected refreshed ETag '%s' got '%s'", `"2000"`, etag)
+	}
+
+	// named the same as Stat, from the Content-Disposition
+	if fi.Name() != "refreshed.txt" {
+		t.Fatalf("Expected refreshed name '%s' got '%s'", "refreshed.txt", fi.Name())
+	}
+
+	// the downloaded file is unchanged
+	if fi, err = f.Stat(); err != nil || fi.Size() != 1000 {
+		t.Fatalf("Expected downloaded size '%d' got '%v' '%v'", 1000, fi, err)
+	}
+
+	slow := &File{url: url + "?slow=1", options: new(Options)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err = slow.RefreshStat(ctx); err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+
+	if _, ok := err.(*DeadlineExceeded); !ok {
+		t.Fatalf("Expected error to be of type *DeadlineExceeded got '%v'", err)
+	}
+}
+
+func TestTempFileName(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "named.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/named.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	var name string
+
+	f, err := Open(url, &Options{
+		TempFileName: func(dir string) (string, error) {
+			name = filepath.Join(dir, "named.txt")
+			return name, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := f.TempFiles()
+	if len(files) != 1 || files[0] != name {
+		t.Fatalf("Expected temp files '[%s]' got '%v'", name, files)
+	}
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	f.Close()
+	assertRemoved(t, files)
+
+	expected := errors.New("no name")
+
+	_, err = Open(url, &Options{
+		TempFileName: func(dir string) (string, error) {
+			return "", expected
+		},
+	})
+	if err != expected {
+		t.Fatalf("Expected '%s' got '%v'", expected, err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(content)
+	zw.Close()
+
+	var m sync.Mutex
+	encodings := make(map[string]string)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		m.Lock()
+		encodings[r.Method+" "+r.Header.Get("Range")] = r.Header.Get("Accept-Encoding")
+		m.Unlock()
+
+		// a server compressing regardless of the range would corrupt a ranged download
+		if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gz.Bytes())
+			return
+		}
+
+		http.ServeContent(w, r, "compressed.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		options  *Options
+		expected map[string]string
+	}{
+		{
+			name: "ranged",
+			options: &Options{
+				MinSizeForRanges: -1,
+				Concurrency: func(size int64) int {
+					return 2
+				},
+				Request: func(r *http.Request) {
+					r.Header.Set("Accept-Encoding", "gzip")
+				},
+			},
+			expected: map[string]string{
+				"HEAD ":             "gzip",
+				"GET bytes=0-499":   "identity",
+				"GET bytes=500-999": "identity",
+			},
+		},
+		{
+			// the transport requests and transparently decompresses the gzip
+			name:    "single",
+			options: &Options{},
+			expected: map[string]string{
+				"HEAD ": "",
+				"GET ":  "gzip",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+
+		url := server.URL + "/" + tt.name + "/compressed.txt"
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		m.Lock()
+		encodings = make(map[string]string)
+		m.Unlock()
+
+		f, err := Open(url, tt.options)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%s: Downloaded content does not match", tt.name)
+		}
+
+		m.Lock()
+		if !reflect.DeepEqual(encodings, tt.expected) {
+			t.Fatalf("%s: Expected Accept-Encoding '%v' got '%v'", tt.name, tt.expected, encodings)
+		}
+		m.Unlock()
+	}
+}
+
+func TestRetry(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var ranges []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rng := r.Header.Get("Range")
+
+		if rng != "" && rng != "bytes=0-0" {
+
+			m.Lock()
+			ranges = append(ranges, rng)
+			attempts := len(ranges)
+			m.Unlock()
+
+			switch {
+			case r.URL.Path == "/always-failing.txt":
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			case attempts == 1:
+				// cut the connection half way through the chunk
+				w.Header().Set("Content-Range", "bytes 0-999/1000")
+				w.Header().Set("Content-Length", "1000")
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(content[:400])
+				return
+			case attempts == 2:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		http.ServeContent(w, r, "retry.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	var backoffs []int
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		MaxRetries:       2,
+		Concurrency: func(size int64) int {
+			return 1
+		},
+		RetryBackoff: func(attempt int) time.Duration {
+			backoffs = append(backoffs, attempt)
+			return time.Millisecond
+		},
+	}
+
+	url := server.URL + "/retry.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	// only the remaining bytes are requested on retry
+	expected := []string{"bytes=0-999", "bytes=400-999", "bytes=400-999"}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("Expected ranges '%v' got '%v'", expected, ranges)
+	}
+
+	if !reflect.DeepEqual(backoffs, []int{1, 2}) {
+		t.Fatalf("Expected backoff attempts '%v' got '%v'", []int{1, 2}, backoffs)
+	}
+
+	// gives up after the retries
+	url = server.URL + "/always-failing.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+	defer os.RemoveAll((&File{url: url}).resumeDir())
+
+	ranges = nil
+
+	var invalid *InvalidResponseCode
+
+	_, err = Open(url, options)
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected error to be of type *InvalidResponseCode got '%v'", err)
+	}
+
+	if len(ranges) != 3 {
+		t.Fatalf("Expected '%d' attempts got '%d'", 3, len(ranges))
+	}
+
+	// cancellation aborts between retries
+	options.RetryBackoff = func(attempt int) time.Duration {
+		return time.Hour
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err = OpenContext(ctx, url, options)
+	if _, ok := err.(*DeadlineExceeded); !ok {
+		t.Fatalf("Expected error to be of type *DeadlineExceeded got '%v'", err)
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("Expected the retry backoff to be aborted")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	requests := make(map[string]http.Header)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		m.Lock()
+		requests[r.Method+" "+r.Header.Get("Range")] = r.Header
+		m.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		http.ServeContent(w, r, "headers.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	for _, minSize := range []int64{0, -1} {
+
+		url := server.URL + "/headers.txt"
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		m.Lock()
+		requests = make(map[string]http.Header)
+		m.Unlock()
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges: minSize,
+			Concurrency: func(size int64) int {
+				return 2
+			},
+			Headers: http.Header{
+				"Authorization": {"Bearer token"},
+				"user-agent":    {"go-download-test"},
+				"X-Overridden":  {"headers"},
+				"Range":         {"bytes=0-9"},
+			},
+			Request: func(r *http.Request) {
+				r.Header.Set("X-Overridden", "request")
+			},
+		})
+		if err != nil {
+			t.Fatalf("%d: %s", minSize, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%d: Downloaded content does not match", minSize)
+		}
+
+		expected := []string{"HEAD ", "GET "}
+		if minSize < 0 {
+			expected = []string{"HEAD ", "GET bytes=0-499", "GET bytes=500-999"}
+		}
+
+		m.Lock()
+
+		if len(requests) != len(expected) {
+			t.Fatalf("%d: Expected '%d' requests got '%d'", minSize, len(expected), len(requests))
+		}
+
+		for _, req := range expected {
+
+			h, ok := requests[req]
+			if !ok {
+				t.Fatalf("%d: Expected request '%s'", minSize, req)
+			}
+
+			if h.Get("User-Agent") != "go-download-test" || h.Get("X-Overridden") != "request" {
+				t.Fatalf("%d: Unexpected headers for '%s' '%v'", minSize, req, h)
+			}
+		}
+
+		m.Unlock()
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if d := r.URL.Query().Get("disposition"); d != "" {
+			w.Header().Set("Content-Disposition", d)
+		}
+
+		http.ServeContent(w, r, "download", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		disposition string
+		expected    string
+	}{
+		{disposition: `attachment; filename="release-1.0.tar.gz"`, expected: "release-1.0.tar.gz"},
+		{disposition: `attachment; filename=report.pdf`, expected: "report.pdf"},
+		{disposition: `attachment; filename*=UTF-8''r%C3%A9sum%C3%A9.txt`, expected: "résumé.txt"},
+		{disposition: `attachment; filename="../../etc/passwd"`, expected: "passwd"},
+		{disposition: `attachment; filename="..\\evil.exe"`, expected: "evil.exe"},
+		{disposition: `attachment; filename=".."`, expected: "download"},
+		{disposition: `attachment`, expected: "download"},
+		{disposition: `;;invalid`, expected: "download"},
+		{disposition: "", expected: "download"},
+	}
+
+	for _, minSize := range []int64{0, -1} {
+
+		for _, tt := range tests {
+
+			url := server.URL + "/download?" + neturl.Values{"disposition": {tt.disposition}}.Encode()
+			os.RemoveAll((&File{url: url}).resumeDir())
+
+			f, err := Open(url, &Options{MinSizeForRanges: minSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fi, err := f.Stat()
+			f.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the url base name includes the query
+			expected := tt.expected
+			if expected == "download" {
+				expected = filepath.Base(url)
+			}
+
+			if fi.Name() != expected {
+				t.Fatalf("Expected name '%s' for '%s' got '%s'", expected, tt.disposition, fi.Name())
+			}
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "writeto.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/writeto.txt"
+
+	for _, minSize := range []int64{0, -1} {
+
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		f, err := Open(url, &Options{MinSizeForRanges: minSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// continues from the current position
+		b := make([]byte, 333)
+		if _, err = io.ReadFull(f, b); err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+
+		n, err := io.Copy(&buf, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 667 || !bytes.Equal(buf.Bytes(), content[333:]) {
+			t.Fatalf("%d: Expected the remaining '%d' bytes got '%d'", minSize, 667, n)
+		}
+
+		if pos, _ := f.Seek(0, io.SeekCurrent); pos != 1000 {
+			t.Fatalf("%d: Expected position '%d' got '%d'", minSize, 1000, pos)
+		}
+
+		// the first error is returned
+		if _, err = f.Seek(500, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := errors.New("write failed")
+
+		if _, err = f.WriteTo(failingWriter{err: expected}); err != expected {
+			t.Fatalf("%d: Expected '%s' got '%v'", minSize, expected, err)
+		}
+
+		f.Close()
+	}
+}
+
+func TestTempDir(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var ranges int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranges, 1)
+		}
+
+		http.ServeContent(w, r, "tempdir.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tempDir, err := ioutil.TempDir("", "go-download-tempdir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	url := server.URL + "/tempdir.txt"
+
+	options := &Options{
+		TempDir:          tempDir,
+		MinSizeForRanges: -1,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	}
+
+	// an interrupted download with only the first chunk complete
+	dir := (&File{url: url, options: options}).resumeDir()
+
+	if filepath.Dir(dir) != tempDir {
+		t.Fatalf("Expected resume directory in '%s' got '%s'", tempDir, dir)
+	}
+
+	if err = os.Mkdir(dir, fileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "0"), content[:250], fileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, minSize := range []int64{-1, 0} {
+
+		atomic.StoreInt32(&ranges, 0)
+		options.MinSizeForRanges = minSize
+
+		f, err := Open(url, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if filepath.Dir(f.dir) != tempDir {
+			f.Close()
+			t.Fatalf("Expected download in '%s' got '%s'", tempDir, f.dir)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		// the complete first chunk is resumed from the custom directory
+		if n := atomic.LoadInt32(&ranges); minSize == -1 && n != 3 {
+			t.Fatalf("Expected '%d' ranged requests got '%d'", 3, n)
+		}
+	}
+
+	infos, err := ioutil.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(infos) != 0 {
+		t.Fatalf("Expected downloads in '%s' to be removed on Close got '%d' left", tempDir, len(infos))
+	}
+}
+
+func TestOpenLazy(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var gets int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/lazy.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&gets, 1)
+		}
+
+		http.ServeContent(w, r, "lazy.txt", time.Time{}, bytes.NewReader(content))
+	})
+	mux.HandleFunc("/testdata/gone.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			http.ServeContent(w, r, "gone.txt", time.Time{}, bytes.NewReader(content))
+			return
+		}
+
+		w.WriteHeader(http.StatusGone)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tempDir, err := ioutil.TempDir("", "go-download-lazy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	url := server.URL + "/testdata/lazy.txt"
+
+	for _, minSize := range []int64{0, -1} {
+
+		atomic.StoreInt32(&gets, 0)
+
+		options := &Options{TempDir: tempDir, MinSizeForRanges: minSize}
+		os.RemoveAll((&File{url: url, options: options}).resumeDir())
+
+		f, err := OpenLazy(context.Background(), url, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			t.Fatal(err)
+		}
+
+		if fi.Size() != int64(len(content)) {
+			f.Close()
+			t.Fatalf("Expected size '%d' got '%d'", len(content), fi.Size())
+		}
+
+		infos, err := ioutil.ReadDir(tempDir)
+		if err != nil {
+			f.Close()
+			t.Fatal(err)
+		}
+
+		if n := atomic.LoadInt32(&gets); n != 0 || len(infos) != 0 {
+			f.Close()
+			t.Fatalf("Expected nothing downloaded before the first Read got '%d' requests and '%d' files", n, len(infos))
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		if n := atomic.LoadInt32(&gets); n == 0 {
+			t.Fatal("Expected the first Read to download the file")
+		}
+	}
+
+	// closing before reading never downloads
+	atomic.StoreInt32(&gets, 0)
+
+	f, err := OpenLazy(context.Background(), url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = f.Read(make([]byte, 1)); err != os.ErrClosed {
+		t.Fatalf("Expected '%v' got '%v'", os.ErrClosed, err)
+	}
+
+	if n := atomic.LoadInt32(&gets); n != 0 {
+		t.Fatalf("Expected no downloads got '%d'", n)
+	}
+
+	// the download error is returned by the first Read
+	f, err = OpenLazy(context.Background(), server.URL+"/testdata/gone.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	_, err = ioutil.ReadAll(f)
+	if _, ok := err.(*InvalidResponseCode); !ok {
+		t.Fatalf("Expected error to be of type *InvalidResponseCode got '%v'", err)
+	}
+}
+
+func TestOpenLazyParallelReadAt(t *testing.T) {
+
+	content := make([]byte, 10000)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+
+	var heads, gets int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		switch r.Method {
+		case http.MethodHead:
+			atomic.AddInt32(&heads, 1)
+		case http.MethodGet:
+			atomic.AddInt32(&gets, 1)
+		}
+
+		http.ServeContent(w, r, "parallel.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/parallel.bin"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	f, err := OpenLazy(context.Background(), url, &Options{
+		MinSizeForRanges: -1,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+
+	for i := 0; i < 10; i++ {
+
+		wg.Add(1)
+
+		go func(off int64) {
+			defer wg.Done()
+
+			b := make([]byte, 500)
+
+			if _, err := f.ReadAt(b, off); err != nil {
+				errs <- err
+				return
+			}
+
+			if !bytes.Equal(b, content[off:off+500]) {
+				errs <- fmt.Errorf("Expected the content at '%d' to match", off)
+			}
+		}(int64(i) * 950)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// downloaded once reusing the HEAD request of OpenLazy
+	if h, g := atomic.LoadInt32(&heads), atomic.LoadInt32(&gets); h != 1 || g != 4 {
+		t.Fatalf("Expected '1' HEAD and '4' GET requests got '%d' and '%d'", h, g)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var methods []string
+
+	capabilities := map[string]http.Header{
+		"/testdata/no-ranges.txt": {"Allow": {"GET, HEAD, OPTIONS"}, "Accept-Ranges": {"none"}},
+		"/testdata/no-head.txt":   {"Allow": {"GET, OPTIONS"}},
+		"/testdata/ranges.txt":    {"Allow": {"OPTIONS, HEAD, GET"}, "Accept-Ranges": {"bytes"}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		m.Lock()
+		methods = append(methods, r.Method)
+		m.Unlock()
+
+		switch r.Method {
+		case http.MethodOptions:
+			for k, v := range capabilities[r.URL.Path] {
+				w.Header()[k] = v
+			}
+			return
+		case http.MethodHead:
+			if r.URL.Path == "/testdata/no-head.txt" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			// ranges are only advertised by the preflight
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			return
+		}
+
+		http.ServeContent(w, r, "preflight.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		url      string
+		methods  []string
+		expected string
+	}{
+		{url: "/testdata/no-ranges.txt", methods: []string{"OPTIONS", "GET"}, expected: "preflight does not allow ranges"},
+		{url: "/testdata/no-head.txt", methods: []string{"OPTIONS", "GET"}, expected: "preflight does not allow HEAD"},
+		{url: "/testdata/ranges.txt", methods: []string{"OPTIONS", "HEAD", "GET", "GET"}, expected: ""},
+	}
+
+	for _, tt := range tests {
+
+		url := server.URL + tt.url
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		methods = nil
+		var reasons []string
+
+		f, err := Open(url, &Options{
+			Preflight:        true,
+			MinSizeForRanges: -1,
+			Concurrency: func(size int64) int {
+				return 2
+			},
+			OnFallback: func(reason string) {
+				reasons = append(reasons, reason)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		if tt.expected == "" && len(reasons) != 0 || tt.expected != "" && (len(reasons) != 1 || reasons[0] != tt.expected) {
+			t.Fatalf("%s: Expected fallback reason '%s' got '%v'", tt.url, tt.expected, reasons)
+		}
+
+		m.Lock()
+		if !reflect.DeepEqual(methods, tt.methods) {
+			t.Fatalf("%s: Expected requests '%v' got '%v'", tt.url, tt.methods, methods)
+		}
+		m.Unlock()
+	}
+}
+
+func TestOpenPartialStatError(t *testing.T) {
+
+	fh, err := ioutil.TempFile("", "go-download-stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.Close()
+	defer os.Remove(fh.Name())
+
+	// the chunk can't be stat'd as the download directory is a file
+	f := &File{dir: fh.Name(), options: new(Options)}
+
+	r, start, complete, err := f.openPartial(true, 0, 10, 19)
+	if err == nil {
+		r.Close()
+		t.Fatal("Expected error got <nil>")
+	}
+
+	if os.IsNotExist(err) || r != nil || start != 10 || complete {
+		t.Fatalf("Expected stat error for unchanged chunk got '%v' start '%d' complete '%t'", err, start, complete)
+	}
+
+	// a missing chunk is created with nothing downloaded
+	dir, err := ioutil.TempDir("", "go-download-stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f.dir = dir
+
+	r, start, complete, err = f.openPartial(true, 0, 10, 19)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	if start != 10 || complete {
+		t.Fatalf("Expected start '%d' and incomplete got '%d' and '%t'", 10, start, complete)
+	}
+}
+
+func TestLastModified(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	modified := time.Date(2017, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/modified.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "modified.txt", modified, bytes.NewReader(content))
+	})
+	mux.HandleFunc("/testdata/bad-head.txt", func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		http.ServeContent(w, r, "bad-head.txt", modified, bytes.NewReader(content))
+	})
+	mux.HandleFunc("/testdata/unmodified.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "unmodified.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		url     string
+		options *Options
+		lazy    bool
+	}{
+		{url: "/testdata/modified.txt", options: &Options{}},
+		{url: "/testdata/modified.txt", options: &Options{MinSizeForRanges: -1}},
+		{url: "/testdata/modified.txt", options: &Options{Suffix: 100}},
+		{url: "/testdata/modified.txt", options: &Options{}, lazy: true},
+		{url: "/testdata/bad-head.txt", options: &Options{}},
+		{url: "/testdata/unmodified.txt", options: &Options{}},
+	}
+
+	for i, tt := range tests {
+
+		url := server.URL + tt.url
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		start := time.Now()
+
+		var f *File
+		var err error
+
+		if tt.lazy {
+			f, err = OpenLazy(context.Background(), url, tt.options)
+		} else {
+			f, err = Open(url, tt.options)
+		}
+
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		fi, err := f.Stat()
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		if tt.url == "/testdata/unmodified.txt" {
+			if fi.ModTime().Before(start) {
+				t.Fatalf("%d: Expected the download time got '%s'", i, fi.ModTime())
+			}
+			continue
+		}
+
+		if !fi.ModTime().Equal(modified) {
+			t.Fatalf("%d: Expected modification time '%s' got '%s'", i, modified, fi.ModTime())
+		}
+	}
+}
+
+func TestFinalURL(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var redirects int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/latest.txt", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&redirects, 1)
+		http.Redirect(w, r, "/testdata/v1.txt", http.StatusFound)
+	})
+	mux.HandleFunc("/testdata/v1.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "v1.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/latest.txt"
+
+	tests := []struct {
+		options   *Options
+		redirects int32
+	}{
+		{options: &Options{}, redirects: 1},
+		{options: &Options{MinSizeForRanges: -1, Concurrency: func(size int64) int { return 4 }}, redirects: 1},
+		// the suffix request is the one redirected as there is no HEAD request
+		{options: &Options{Suffix: 100}, redirects: 1},
+	}
+
+	for i, tt := range tests {
+
+		os.RemoveAll((&File{url: url}).resumeDir())
+		atomic.StoreInt32(&redirects, 0)
+
+		f, err := Open(url, tt.options)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		expected := content
+		if tt.options.Suffix > 0 {
+			expected = content[len(content)-100:]
+		}
+
+		if !bytes.Equal(b, expected) {
+			t.Fatalf("%d: Downloaded content does not match", i)
+		}
+
+		// only the first request is redirected
+		if n := atomic.LoadInt32(&redirects); n != tt.redirects {
+			t.Fatalf("%d: Expected '%d' redirects got '%d'", i, tt.redirects, n)
+		}
+
+		if final := f.FinalURL(); final != server.URL+"/testdata/v1.txt" {
+			t.Fatalf("%d: Expected final url '%s' got '%s'", i, server.URL+"/testdata/v1.txt", final)
+		}
+	}
+
+	// not redirected
+	f, err := Open(server.URL+"/testdata/v1.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if final := f.FinalURL(); final != server.URL+"/testdata/v1.txt" {
+		t.Fatalf("Expected final url '%s' got '%s'", server.URL+"/testdata/v1.txt", final)
+	}
+}
+
+func TestCrossHostRedirect(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var leaked []string
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+			m.Lock()
+			leaked = append(leaked, r.Method+" "+r.Header.Get("Range"))
+			m.Unlock()
+		}
+
+		http.ServeContent(w, r, "moved.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer target.Close()
+
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/moved.txt", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	// localhost and 127.0.0.1 are different hosts to net/http
+	_, port, err := net.SplitHostPort(origin.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := "http://localhost:" + port + "/moved.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		Headers:          http.Header{"Authorization": {"Bearer secret"}, "Cookie": {"session=secret"}},
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	}
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	if len(leaked) > 0 {
+		t.Fatalf("Expected no credentials sent to the redirected host got '%v'", leaked)
+	}
+
+	if final := f.FinalURL(); final != target.URL+"/moved.txt" {
+		t.Fatalf("Expected final url '%s' got '%s'", target.URL+"/moved.txt", final)
+	}
+}
+
+func TestUpgradeToHTTPS(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	handler := func(scheme string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, scheme+".txt", time.Time{}, bytes.NewReader(append([]byte(scheme), content...)))
+		}
+	}
+
+	secure := httptest.NewTLSServer(handler("https"))
+	defer secure.Close()
+
+	legacy := httptest.NewServer(handler("http"))
+	defer legacy.Close()
+
+	// trusts the test certificate
+	client := func() http.Client {
+		return *secure.Client()
+	}
+
+	tests := []struct {
+		url    string
+		scheme string
+	}{
+		{url: strings.Replace(secure.URL, "https://", "http://", 1) + "/testdata/secure.txt", scheme: "https"},
+		{url: legacy.URL + "/testdata/legacy.txt", scheme: "http"},
+	}
+
+	for _, tt := range tests {
+		for _, minSize := range []int64{0, -1} {
+
+			f, err := Open(tt.url, &Options{MinSizeForRanges: minSize, UpgradeToHTTPS: true, Client: client})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := ioutil.ReadAll(f)
+			f.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, append([]byte(tt.scheme), content...)) {
+				t.Fatalf("Expected the content to be downloaded over '%s'", tt.scheme)
+			}
+
+			if !strings.HasPrefix(f.FinalURL(), tt.scheme+"://") {
+				t.Fatalf("Expected '%s' url got '%s'", tt.scheme, f.FinalURL())
+			}
+		}
+	}
+}
+
+func TestOpenRequest(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var requests []string
+	var invalid []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		b, _ := ioutil.ReadAll(r.Body)
+
+		m.Lock()
+		requests = append(requests, r.Method)
+		if r.Header.Get("X-Signature") != "signed" || len(b) > 0 || r.URL.Query().Get("token") != "abc" {
+			invalid = append(invalid, fmt.Sprintf("%s %s %v body '%s'", r.Method, r.URL, r.Header, b))
+		}
+		m.Unlock()
+
+		http.ServeContent(w, r, "signed.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	for _, minSize := range []int64{0, -1} {
+
+		// the template's method, body and range are replaced
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/testdata/signed.txt?token=abc", strings.NewReader("ignored"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Signature", "signed")
+		req.Header.Set("Range", "bytes=0-9")
+
+		os.RemoveAll((&File{url: req.URL.String()}).resumeDir())
+
+		m.Lock()
+		requests, invalid = nil, nil
+		m.Unlock()
+
+		f, err := OpenRequest(context.Background(), req, &Options{
+			MinSizeForRanges: minSize,
+			Concurrency: func(size int64) int {
+				return 2
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		expected := []string{http.MethodHead, http.MethodGet}
+		if minSize < 0 {
+			expected = append(expected, http.MethodGet)
+		}
+
+		m.Lock()
+		if !reflect.DeepEqual(requests, expected) {
+			t.Fatalf("Expected requests '%v' got '%v'", expected, requests)
+		}
+
+		if len(invalid) > 0 {
+			t.Fatalf("Expected requests cloned from the template got '%v'", invalid)
+		}
+		m.Unlock()
+	}
+}
+
+func TestTinyFileHighConcurrency(t *testing.T) {
+
+	content := []byte("abc")
+
+	var ranges int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranges, 1)
+		}
+
+		http.ServeContent(w, r, "tiny.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/tiny.txt"
+
+	for _, exponential := range []bool{false, true} {
+
+		os.RemoveAll((&File{url: url}).resumeDir())
+		atomic.StoreInt32(&ranges, 0)
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges:  -1,
+			ExponentialChunks: exponential,
+			Concurrency: func(size int64) int {
+				return 10
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("Expected '%s' got '%s'", content, b)
+		}
+
+		// one chunk per byte
+		if n := atomic.LoadInt32(&ranges); n != 3 {
+			t.Fatalf("Expected '%d' ranged requests got '%d'", 3, n)
+		}
+	}
+}
+
+func TestContentChanged(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var chunks int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		etag := `"v1"`
+
+		// the file changes after the first two chunks are requested
+		if r.Header.Get("Range") != "" && atomic.AddInt32(&chunks, 1) > 2 {
+			etag = `"v2"`
+		}
+
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "changing.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/changing.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+	defer os.RemoveAll((&File{url: url}).resumeDir())
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		MaxRetries:       2,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	}
+
+	_, err := Open(url, options)
+
+	var changed *ContentChanged
+	if !errors.As(err, &changed) {
+		t.Fatalf("Expected error to be of type *ContentChanged got '%v'", err)
+	}
+
+	expected := "Content changed for '" + url + "', received ETag '\"v2\"' expected '\"v1\"'"
+	if changed.Error() != expected {
+		t.Fatalf("Expected '%s' got '%s'", expected, changed.Error())
+	}
+
+	// a consistent ETag downloads as normal
+	os.RemoveAll((&File{url: url}).resumeDir())
+	atomic.StoreInt32(&chunks, -100)
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
+
+func TestChunkFailureCleanup(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var recovered int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// the last chunk fails once the others are underway
+		if r.Header.Get("Range") == "bytes=750-999" && atomic.LoadInt32(&recovered) == 0 {
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		http.ServeContent(w, r, "cleanup.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/cleanup.txt"
+
+	tests := []struct {
+		name string
+		open func(options *Options) (*File, error)
+		kept bool
+	}{
+		{
+			name: "no resume",
+			open: func(options *Options) (*File, error) {
+				options.NoResume = true
+				return Open(url, options)
+			},
+		},
+		{
+			name: "ranges",
+			open: func(options *Options) (*File, error) {
+				return OpenRanges(context.Background(), url, [][2]int64{{0, 99}, {750, 999}}, options)
+			},
+		},
+		{
+			name: "resumable",
+			open: func(options *Options) (*File, error) {
+				return Open(url, options)
+			},
+		},
+		{
+			// kept for the next download of the url to resume
+			name: "keep on failure",
+			open: func(options *Options) (*File, error) {
+				options.KeepOnFailure = true
+				return Open(url, options)
+			},
+			kept: true,
+		},
+	}
+
+	for _, tt := range tests {
+
+		dir, err := ioutil.TempDir("", "cleanup")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		options := &Options{
+			TempDir:          dir,
+			MinSizeForRanges: -1,
+			Concurrency: func(size int64) int {
+				return 4
+			},
+		}
+
+		f, err := tt.open(options)
+		if err == nil {
+			f.Close()
+			os.RemoveAll(dir)
+			t.Fatalf("%s: Expected error got <nil>", tt.name)
+		}
+
+		if f != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("%s: Expected no File got '%v'", tt.name, f)
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+
+		if kept := len(files) > 0; kept != tt.kept {
+			os.RemoveAll(dir)
+			t.Fatalf("%s: Expected the downloaded chunks kept '%t' got '%d' files", tt.name, tt.kept, len(files))
+		}
+
+		if !tt.kept {
+			os.RemoveAll(dir)
+			continue
+		}
+
+		// the next download resumes the kept chunks and removes them once closed
+		atomic.StoreInt32(&recovered, 1)
+
+		f, err = tt.open(options)
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		f.Close()
+
+		files, err = ioutil.ReadDir(dir)
+		os.RemoveAll(dir)
+		atomic.StoreInt32(&recovered, 0)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(files) > 0 {
+			t.Fatalf("%s: Expected no files left once resumed got '%d'", tt.name, len(files))
+		}
+	}
+}
+
+func TestHeadFailedSize(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		http.ServeContent(w, r, "nohead.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	for _, inMemory := range []bool{false, true} {
+
+		var buf bytes.Buffer
+
+		f, err := Open(server.URL+"/nohead.txt", &Options{
+			InMemory:      inMemory,
+			VerifyOnClose: true,
+			Logger:        log.New(&buf, "", 0),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			t.Fatal(err)
+		}
+
+		if fi.Size() != int64(len(content)) {
+			f.Close()
+			t.Fatalf("Expected size '%d' got '%d'", len(content), fi.Size())
+		}
+
+		if err = f.Close(); err != nil {
+			t.Fatalf("Expected <nil> got '%v'", err)
+		}
+
+		if strings.Contains(buf.String(), "Short download") {
+			t.Fatalf("Expected no short download warning got '%s'", buf.String())
+		}
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		backoff BackoffFn
+	}{
+		{name: "default"},
+		{name: "custom", backoff: func(attempt int) time.Duration {
+			return time.Duration(attempt*attempt) * time.Millisecond
+		}},
+		{name: "zero", backoff: func(attempt int) time.Duration {
+			return 0
+		}},
+	}
+
+	for _, tt := range tests {
+
+		options := &Options{
+			RetryBackoff: tt.backoff,
+			MinBackoff:   50 * time.Millisecond,
+			MaxBackoff:   5 * time.Second,
+		}
+
+		for attempt := 1; attempt <= 100; attempt++ {
+			if d := options.retryBackoff(attempt); d < options.MinBackoff || d > options.MaxBackoff {
+				t.Fatalf("%s: Expected attempt '%d' backoff within '%s-%s' got '%s'", tt.name, attempt, options.MinBackoff, options.MaxBackoff, d)
+			}
+		}
+	}
+
+	// unbounded by default
+	if d := new(Options).retryBackoff(10); d != defaultRetryBackoff<<9 {
+		t.Fatalf("Expected '%s' got '%s'", defaultRetryBackoff<<9, d)
+	}
+}
@@ -0,0 +1,134 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+const equalBufferSize = 32 * 1024
+
+// Equal streams url1 and url2 concurrently and reports whether they are byte for byte identical,
+// comparing them as they arrive without writing either to disk. Both downloads are cancelled at
+// the first difference, or straight away when their Content-Lengths differ.
+func Equal(ctx context.Context, url1, url2 string, options *Options) (bool, error) {
+
+	for _, url := range []string{url1, url2} {
+		if err := httpOnly(url, "Equal"); err != nil {
+			return false, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+
+	ch1 := make(chan result, 1)
+	ch2 := make(chan result, 1)
+
+	get := func(url string, ch chan<- result) {
+		resp, err := equalGet(ctx, url, options)
+		if err != nil {
+			// no need to finish the other request
+			cancel()
+		}
+		ch <- result{resp: resp, err: err}
+	}
+
+	go get(url1, ch1)
+	go get(url2, ch2)
+
+	res1, res2 := <-ch1, <-ch2
+
+	if res1.resp != nil {
+		defer res1.resp.Body.Close()
+	}
+
+	if res2.resp != nil {
+		defer res2.resp.Body.Close()
+	}
+
+	// report the original error rather than the resulting cancellation
+	switch {
+	case res1.err != nil && res2.err != nil && ctx.Err() != nil:
+		if _, ok := res1.err.(*Canceled); ok {
+			return false, res2.err
+		}
+		return false, res1.err
+	case res1.err != nil:
+		return false, res1.err
+	case res2.err != nil:
+		return false, res2.err
+	}
+
+	if l1, l2 := res1.resp.ContentLength, res2.resp.ContentLength; l1 >= 0 && l2 >= 0 && l1 != l2 {
+		return false, nil
+	}
+
+	return equalReaders(res1.resp.Body, res2.resp.Body)
+}
+
+// equalGet requests the whole file at url for streaming by Equal
+func equalGet(ctx context.Context, url string, options *Options) (*http.Response, error) {
+
+	f, err := newFile(url, options)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := f.newRequest(ctx, http.MethodGet, f.url)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, f.contextErr(ctx)
+		}
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &InvalidResponseCode{got: resp.StatusCode, expected: http.StatusOK}
+	}
+
+	return resp, nil
+}
+
+// equalReaders compares r1 and r2 until the first difference
+func equalReaders(r1, r2 io.Reader) (bool, error) {
+
+	b1 := make([]byte, equalBufferSize)
+	b2 := make([]byte, equalBufferSize)
+
+	for {
+		n1, err1 := io.ReadFull(r1, b1)
+		if err1 != nil && err1 != io.EOF && err1 != io.ErrUnexpectedEOF {
+			return false, err1
+		}
+
+		n2, err2 := io.ReadFull(r2, b2)
+		if err2 != nil && err2 != io.EOF && err2 != io.ErrUnexpectedEOF {
+			return false, err2
+		}
+
+		if !bytes.Equal(b1[:n1], b2[:n2]) {
+			return false, nil
+		}
+
+		if err1 != nil || err2 != nil {
+			return err1 != nil && err2 != nil, nil
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEqual(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	different := make([]byte, len(content))
+	copy(different, content)
+	different[len(different)-1] = 'x'
+
+	fixtures := map[string][]byte{
+		"/a.txt":         content,
+		"/b.txt":         content,
+		"/different.txt": different,
+		"/shorter.txt":   content[:len(content)-1],
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		b, ok := fixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(b))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		url1     string
+		url2     string
+		expected bool
+	}{
+		{url1: "/a.txt", url2: "/b.txt", expected: true},
+		{url1: "/a.txt", url2: "/different.txt", expected: false},
+		{url1: "/a.txt", url2: "/shorter.txt", expected: false},
+	}
+
+	options := &Options{MinSizeForRanges: -1}
+
+	for i, tt := range tests {
+
+		for _, path := range []string{tt.url1, tt.url2} {
+			os.RemoveAll((&File{url: server.URL + path}).resumeDir())
+		}
+
+		equal, err := Equal(context.Background(), server.URL+tt.url1, server.URL+tt.url2, options)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		if equal != tt.expected {
+			t.Fatalf("%d: Expected '%t' got '%t'", i, tt.expected, equal)
+		}
+	}
+
+	if _, err := Equal(context.Background(), server.URL+"/a.txt", server.URL+"/missing.txt", options); err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+
+	// streamed without being written to disk
+	options.TempDir = t.TempDir()
+
+	if _, err := Equal(context.Background(), server.URL+"/a.txt", server.URL+"/b.txt", options); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, _ := ioutil.ReadDir(options.TempDir); len(files) > 0 {
+		t.Fatalf("Expected no files written got '%d'", len(files))
+	}
+
+	if ok, err := equalReaders(bytes.NewReader(content), bytes.NewReader(content[:500])); ok || err != nil {
+		t.Fatalf("Expected 'false' and <nil> got '%t' and '%v'", ok, err)
+	}
+}
+
+func TestEqualStopsAtDifference(t *testing.T) {
+
+	const size = 64 << 20
+
+	var sent int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		fill := byte('a')
+		if r.URL.Path == "/b.bin" {
+			fill = 'b'
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(size))
+
+		buf := bytes.Repeat([]byte{fill}, 32*1024)
+
+		for written := 0; written < size; written += len(buf) {
+			if _, err := w.Write(buf); err != nil {
+				return
+			}
+			atomic.AddInt64(&sent, int64(len(buf)))
+		}
+	}))
+	defer server.Close()
+
+	equal, err := Equal(context.Background(), server.URL+"/a.bin", server.URL+"/b.bin", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if equal {
+		t.Fatal("Expected 'false' got 'true'")
+	}
+
+	// give the handlers time to notice the closed connections
+	time.Sleep(50 * time.Millisecond)
+
+	if n := atomic.LoadInt64(&sent); n >= size {
+		t.Fatalf("Expected the downloads to stop early got '%d' bytes sent", n)
+	}
+}
@@ -6,6 +6,21 @@ var (
 	_ error = (*InvalidResponseCode)(nil)
 	_ error = (*DeadlineExceeded)(nil)
 	_ error = (*Canceled)(nil)
+	_ error = (*MagicMismatch)(nil)
+	_ error = (*RangeMismatch)(nil)
+	_ error = (*ShortDownload)(nil)
+	_ error = (*MaxSizeExceeded)(nil)
+	_ error = (*InvalidPath)(nil)
+	_ error = (*ChunkError)(nil)
+	_ error = (*ChecksumMismatch)(nil)
+	_ error = (*PieceMismatch)(nil)
+	_ error = (*RangeNotSupported)(nil)
+	_ error = (*InvalidContent)(nil)
+	_ error = (*Rejected)(nil)
+	_ error = (*ProvenanceMismatch)(nil)
+	_ error = (*ContentChanged)(nil)
+	_ error = (*RangeNotDownloaded)(nil)
+	_ error = (*UnsupportedProtocol)(nil)
 )
 
 // InvalidResponseCode is the error containing the invalid response code error information
@@ -22,13 +37,24 @@ func (e *InvalidResponseCode) Error() string {
 // DeadlineExceeded is the error containing the deadline exceeded error information
 type DeadlineExceeded struct {
 	url string
+	err error
 }
 
 // Error returns the DeadlineExceeded error string
 func (e *DeadlineExceeded) Error() string {
+
+	if e.err != nil {
+		return fmt.Sprintf("Download timeout exceeded for '%s', last error: %s", e.url, e.err)
+	}
+
 	return fmt.Sprintf("Download timeout exceeded for '%s'", e.url)
 }
 
+// Unwrap returns the last error before the timeout, if any
+func (e *DeadlineExceeded) Unwrap() error {
+	return e.err
+}
+
 // Canceled is the error containing the cancelled error information
 type Canceled struct {
 	url string
@@ -38,3 +64,195 @@ type Canceled struct {
 func (e *Canceled) Error() string {
 	return fmt.Sprintf("Download canceled for '%s'", e.url)
 }
+
+// MagicMismatch is the error containing the magic bytes mismatch error information
+type MagicMismatch struct {
+	expected []byte
+	got      []byte
+}
+
+// Error returns the MagicMismatch error string
+func (e *MagicMismatch) Error() string {
+	return fmt.Sprintf("Invalid magic bytes, received '%x' expected '%x'", e.got, e.expected)
+}
+
+// RangeMismatch is the error containing the Content-Range mismatch error information
+type RangeMismatch struct {
+	expected chunk
+	got      chunk
+}
+
+// Error returns the RangeMismatch error string
+func (e *RangeMismatch) Error() string {
+	return fmt.Sprintf("Invalid Content-Range, received 'bytes %d-%d' expected 'bytes %d-%d'", e.got.start, e.got.end, e.expected.start, e.expected.end)
+}
+
+// ShortDownload is the error containing the downloaded size mismatch error information
+type ShortDownload struct {
+	expected int64
+	got      int64
+}
+
+// Error returns the ShortDownload error string
+func (e *ShortDownload) Error() string {
+	return fmt.Sprintf("Short download, received '%d' bytes expected '%d'", e.got, e.expected)
+}
+
+// MaxSizeExceeded is the error containing the declared maximum size error information
+type MaxSizeExceeded struct {
+	max int64
+	got int64
+}
+
+// Error returns the MaxSizeExceeded error string
+func (e *MaxSizeExceeded) Error() string {
+	return fmt.Sprintf("Max size exceeded, received '%d' bytes expected at most '%d'", e.got, e.max)
+}
+
+// InvalidPath is the error containing the invalid archive entry path error information
+type InvalidPath struct {
+	name string
+}
+
+// Error returns the InvalidPath error string
+func (e *InvalidPath) Error() string {
+	return fmt.Sprintf("Invalid archive path '%s', outside of the destination directory", e.name)
+}
+
+// ChunkError is the error containing the chunk index and inclusive byte range of a failed partial download
+type ChunkError struct {
+	idx   int
+	start int64
+	end   int64
+	err   error
+}
+
+// Error returns the ChunkError error string
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("Chunk '%d' bytes '%d-%d' failed: %s", e.idx, e.start, e.end, e.err)
+}
+
+// Unwrap returns the error the chunk failed with
+func (e *ChunkError) Unwrap() error {
+	return e.err
+}
+
+// Index returns the index of the failed chunk
+func (e *ChunkError) Index() int {
+	return e.idx
+}
+
+// Range returns the inclusive byte range of the failed chunk
+func (e *ChunkError) Range() (start, end int64) {
+	return e.start, e.end
+}
+
+// ChecksumMismatch is the error containing the checksum mismatch error information
+type ChecksumMismatch struct {
+	algorithm Algorithm
+	expected  string
+	got       string
+}
+
+// Error returns the ChecksumMismatch error string
+func (e *ChecksumMismatch) Error() string {
+	return fmt.Sprintf("Invalid %s checksum, received '%s' expected '%s'", e.algorithm, e.got, e.expected)
+}
+
+// PieceMismatch is the error containing the piece hash mismatch error information
+type PieceMismatch struct {
+	piece    int
+	start    int64
+	end      int64
+	expected []byte
+	got      []byte
+}
+
+// Error returns the PieceMismatch error string
+func (e *PieceMismatch) Error() string {
+	return fmt.Sprintf("Invalid hash for piece '%d' bytes '%d-%d', received '%x' expected '%x'", e.piece, e.start, e.end, e.got, e.expected)
+}
+
+// RangeNotSupported is the error returned when the server responds to a ranged
+// request with the whole file, despite advertising range support
+type RangeNotSupported struct {
+	url string
+}
+
+// Error returns the RangeNotSupported error string
+func (e *RangeNotSupported) Error() string {
+	return fmt.Sprintf("Range request ignored by server for '%s'", e.url)
+}
+
+// InvalidContent is the error containing the malformed content error information
+type InvalidContent struct {
+	format string
+	reason string
+}
+
+// Error returns the InvalidContent error string
+func (e *InvalidContent) Error() string {
+	return fmt.Sprintf("Invalid %s content, %s", e.format, e.reason)
+}
+
+// Rejected is the error returned when the Options.Accept function rejected the download
+type Rejected struct {
+	url string
+}
+
+// Error returns the Rejected error string
+func (e *Rejected) Error() string {
+	return fmt.Sprintf("Download rejected for '%s'", e.url)
+}
+
+// ProvenanceMismatch is the error containing the provenance mismatch error information
+type ProvenanceMismatch struct {
+	check    string
+	expected string
+	got      string
+}
+
+// Error returns the ProvenanceMismatch error string
+func (e *ProvenanceMismatch) Error() string {
+	return fmt.Sprintf("Invalid provenance %s, received '%s' expected '%s'", e.check, e.got, e.expected)
+}
+
+// Check returns the provenance check that failed, "size" or "sha256"
+func (e *ProvenanceMismatch) Check() string {
+	return e.check
+}
+
+// ContentChanged is the error returned when the remote file changed during the download
+type ContentChanged struct {
+	url      string
+	expected string
+	got      string
+}
+
+// Error returns the ContentChanged error string
+func (e *ContentChanged) Error() string {
+	return fmt.Sprintf("Content changed for '%s', received ETag '%s' expected '%s'", e.url, e.got, e.expected)
+}
+
+// RangeNotDownloaded is the error returned when reading a gap between the ranges downloaded by OpenRanges
+type RangeNotDownloaded struct {
+	start int64
+	end   int64
+}
+
+// Error returns the RangeNotDownloaded error string
+func (e *RangeNotDownloaded) Error() string {
+	return fmt.Sprintf("Range not downloaded, bytes '%d-%d'", e.start, e.end)
+}
+
+// UnsupportedProtocol is the error returned when a feature implemented only over http is used
+// with a url of another protocol, eg. ftp://
+type UnsupportedProtocol struct {
+	scheme  string
+	feature string
+}
+
+// Error returns the UnsupportedProtocol error string
+func (e *UnsupportedProtocol) Error() string {
+	return fmt.Sprintf("Unsupported protocol '%s', %s requires http or https", e.scheme, e.feature)
+}
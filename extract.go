@@ -0,0 +1,87 @@
+package download
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractTarGz extracts the downloaded .tar.gz file into destDir, returning an *InvalidPath
+// error for entries that would be written outside of it eg. '../etc/passwd'. Only
+// directories and regular files are extracted, other entries such as links are skipped.
+func (f *File) ExtractTarGz(destDir string) error {
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		path, err := extractPath(destDir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = extractFile(path, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractPath returns the path within destDir to extract the archive entry name to
+func extractPath(destDir, name string) (string, error) {
+
+	path := filepath.Join(destDir, name)
+
+	rel, err := filepath.Rel(destDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", &InvalidPath{name: name}
+	}
+
+	return path, nil
+}
+
+// extractFile writes the content of r to path, creating any missing parent directories
+func extractFile(path string, r io.Reader, mode os.FileMode) error {
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	fh, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	if _, err = io.Copy(fh, r); err != nil {
+		return err
+	}
+
+	return fh.Close()
+}
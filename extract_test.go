@@ -0,0 +1,129 @@
+package download
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type tarEntry struct {
+	name    string
+	content string
+	dir     bool
+}
+
+func tarGz(t *testing.T, entries []tarEntry) []byte {
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, e := range entries {
+
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.dir {
+			hdr.Mode, hdr.Typeflag = 0755, tar.TypeDir
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestExtractTarGz(t *testing.T) {
+
+	fixtures := map[string][]byte{
+		"/release.src.tar.gz": tarGz(t, []tarEntry{
+			{name: "release/", dir: true},
+			{name: "release/README", content: "read me"},
+			{name: "release/src/main.go", content: "package main"},
+		}),
+		"/malicious.tar.gz": tarGz(t, []tarEntry{
+			{name: "release/README", content: "read me"},
+			{name: "release/../../escaped", content: "gotcha"},
+		}),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(fixtures[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	parent, err := ioutil.TempDir("", "go-download-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(parent)
+
+	dest := filepath.Join(parent, "dest")
+
+	f, err := Open(server.URL+"/release.src.tar.gz", &Options{MinSizeForRanges: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err = f.ExtractTarGz(dest); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"release/README":      "read me",
+		"release/src/main.go": "package main",
+	}
+
+	for name, content := range expected {
+
+		b, err := ioutil.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != content {
+			t.Fatalf("Expected '%s' content '%s' got '%s'", name, content, string(b))
+		}
+	}
+
+	f, err = Open(server.URL+"/malicious.tar.gz", &Options{MinSizeForRanges: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	err = f.ExtractTarGz(dest)
+	if _, ok := err.(*InvalidPath); !ok {
+		t.Fatalf("Expected *InvalidPath error got '%v'", err)
+	}
+
+	expectedErr := "Invalid archive path 'release/../../escaped', outside of the destination directory"
+	if err.Error() != expectedErr {
+		t.Fatalf("Expected '%s' got '%s'", expectedErr, err)
+	}
+
+	if _, err = os.Stat(filepath.Join(parent, "escaped")); !os.IsNotExist(err) {
+		t.Fatalf("Expected escaped file not to exist got '%v'", err)
+	}
+}
@@ -12,6 +12,7 @@ type fileInfo struct {
 	size    int64
 	mode    os.FileMode
 	modTime time.Time
+	sys     interface{}
 }
 
 // Name returns the base name of the file
@@ -38,10 +39,10 @@ func (f *fileInfo) ModTime() time.Time {
 
 // IsDir returns if the file is a directory
 func (f *fileInfo) IsDir() bool {
-	return false
+	return f.mode.IsDir()
 }
 
 // Sys returns the underlying data source (can return nil)
 func (f *fileInfo) Sys() interface{} {
-	return nil
+	return f.sys
 }
@@ -0,0 +1,106 @@
+package download
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+var (
+	_ fs.File        = (*File)(nil)
+	_ fs.FS          = (*fileFS)(nil)
+	_ fs.ReadDirFile = (*fsDir)(nil)
+)
+
+// FSFile returns the File as an fs.File for io/fs consumers, reading
+// from the File's read position. Closing it closes the File.
+func (f *File) FSFile() fs.File {
+	return f
+}
+
+// FS returns a single file fs.FS containing the File under it's Stat name in the root
+// directory, eg. to pass the download to io/fs based tooling. Each Open returns a view with it's own read position,
+// closing a view doesn't close the File.
+func (f *File) FS() fs.FS {
+	return &fileFS{f: f}
+}
+
+// fileFS is the single file fs.FS of a File
+type fileFS struct {
+	f *File
+}
+
+// Open opens the File when name is it's Stat name, or the root directory when name is "."
+func (fsys *fileFS) Open(name string) (fs.File, error) {
+
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	fi, err := fsys.f.Stat()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrClosed}
+	}
+
+	if name == "." {
+		return &fsDir{fi: fi}, nil
+	}
+
+	if name != fi.Name() {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return &fsView{SectionReader: io.NewSectionReader(fsys.f, 0, fi.Size()), fi: fi}, nil
+}
+
+// fsView is a view of a File with it's own read position
+type fsView struct {
+	*io.SectionReader
+	fi fs.FileInfo
+}
+
+// Stat returns the FileInfo of the File
+func (v *fsView) Stat() (fs.FileInfo, error) {
+	return v.fi, nil
+}
+
+// Close is a no-op, the File remains open
+func (v *fsView) Close() error {
+	return nil
+}
+
+// fsDir is the read-only root directory of a fileFS, listing the File as it's only entry
+type fsDir struct {
+	fi   fs.FileInfo
+	read bool
+}
+
+// Stat returns the FileInfo of the directory
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return &fileInfo{name: ".", mode: fs.ModeDir | 0555, modTime: d.fi.ModTime()}, nil
+}
+
+// Read fails, the directory can only be listed
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: errors.New("is a directory")}
+}
+
+// ReadDir returns the File's entry the first time it's called
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+
+	if d.read {
+		if n > 0 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	}
+
+	d.read = true
+
+	return []fs.DirEntry{fs.FileInfoToDirEntry(d.fi)}, nil
+}
+
+// Close is a no-op
+func (d *fsDir) Close() error {
+	return nil
+}
@@ -0,0 +1,97 @@
+package download
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFS(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "fs.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/fs.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	for _, minSize := range []int64{0, -1} {
+
+		f, err := Open(url, &Options{MinSizeForRanges: minSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fsys := f.FS()
+
+		// each Open is independent of the others and the File
+		if err = fstest.TestFS(fsys, "fs.txt"); err != nil {
+			f.Close()
+			t.Fatal(err)
+		}
+
+		b, err := fs.ReadFile(fsys, "fs.txt")
+		if err != nil {
+			f.Close()
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			f.Close()
+			t.Fatal("Downloaded content does not match")
+		}
+
+		if matches, err := fs.Glob(fsys, "*.txt"); err != nil || len(matches) != 1 || matches[0] != "fs.txt" {
+			f.Close()
+			t.Fatalf("Expected '[fs.txt]' got '%v' '%v'", matches, err)
+		}
+
+		if _, err = fs.ReadFile(fsys, "other.txt"); !errors.Is(err, fs.ErrNotExist) {
+			f.Close()
+			t.Fatalf("Expected '%v' got '%v'", fs.ErrNotExist, err)
+		}
+
+		if _, err = fsys.Open("../fs.txt"); !errors.Is(err, fs.ErrInvalid) {
+			f.Close()
+			t.Fatalf("Expected '%v' got '%v'", fs.ErrInvalid, err)
+		}
+
+		file := f.FSFile()
+
+		fi, err := file.Stat()
+		if err != nil {
+			f.Close()
+			t.Fatal(err)
+		}
+
+		if fi.Name() != "fs.txt" || fi.Size() != int64(len(content)) {
+			f.Close()
+			t.Fatalf("Expected 'fs.txt' of size '%d' got '%s' of size '%d'", len(content), fi.Name(), fi.Size())
+		}
+
+		b, err = ioutil.ReadAll(file)
+		file.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		if _, err = fsys.Open("fs.txt"); !errors.Is(err, fs.ErrClosed) {
+			t.Fatalf("Expected '%v' got '%v'", fs.ErrClosed, err)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFTPPort     = "21"
+	defaultFTPUser     = "anonymous"
+	defaultFTPPassword = "anonymous@"
+	mdtmFormat         = "20060102150405"
+)
+
+// ftpConn is an FTP control connection
+type ftpConn struct {
+	*textproto.Conn
+	host   string
+	dialer *net.Dialer
+}
+
+// downloadFTP downloads the file from an ftp:// url in a single stream, using the SIZE and MDTM
+// commands for Stat and REST to resume an interrupted download, where supported by the server.
+func (f *File) downloadFTP(ctx context.Context) error {
+
+	u, err := url.Parse(f.url)
+	if err != nil {
+		return err
+	}
+
+	// the path is sent as is in the commands, a line break would end the command early
+	if strings.ContainsAny(u.Path, "\r\n") {
+		return fmt.Errorf("Invalid FTP path %q, control characters are not allowed", u.Path)
+	}
+
+	c, err := f.dialFTP(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	stop := closeOnDone(ctx, c)
+	defer stop()
+
+	// the path is relative to the login directory, as in RFC 1738
+	if err = f.retrieveFTP(ctx, c, strings.TrimPrefix(u.Path, "/")); err != nil && ctx.Err() != nil {
+		return f.contextErr(ctx)
+	}
+
+	return err
+}
+
+// retrieveFTP retrieves the file at path over the logged in control connection c
+func (f *File) retrieveFTP(ctx context.Context, c *ftpConn, path string) error {
+
+	// SIZE and MDTM are extensions so the size and modification time may be unknown
+	f.size = -1
+
+	if _, msg, err := c.cmd("SIZE "+path, 213); err == nil {
+		if f.size, err = strconv.ParseInt(strings.TrimSpace(msg), 10, 64); err != nil {
+			f.size = -1
+		}
+	}
+
+	if _, msg, err := c.cmd("MDTM "+path, 213); err == nil && len(msg) >= len(mdtmFormat) {
+		if t, err := time.Parse(mdtmFormat, msg[:len(mdtmFormat)]); err == nil {
+			f.lastModified = t.Format(http.TimeFormat)
+		}
+	}
+
+	partial := filepath.Join(f.resumeDir(), partialName)
+
+	var offset int64
+
+	if fi, err := os.Stat(partial); err == nil && !f.options.InMemory {
+		if !f.options.NoResume && f.worthResuming(fi.Size()) {
+			offset = fi.Size()
+		} else {
+			os.Remove(partial)
+		}
+	}
+
+	if offset > 0 {
+		if _, _, err := c.cmd("REST "+strconv.FormatInt(offset, 10), 350); err != nil {
+			offset = 0 // the server can't restart, the partial is replaced
+		}
+	}
+
+	data, err := c.retr(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	stop := closeOnDone(ctx, data)
+	defer stop()
+
+	length := f.size
+	if length > 0 {
+		length -= offset
+	}
+
+	err = f.receive(ctx, data, offset, length)
+	data.Close()
+
+	if err != nil {
+		return err
+	}
+
+	// the transfer is only complete once the server says so
+	_, _, err = c.ReadResponse(226)
+	return ftpErr(err, 226)
+}
+
+// dialFTP connects and logs in to the FTP server of u, anonymously if u has no user
+func (f *File) dialFTP(ctx context.Context, u *url.URL) (*ftpConn, error) {
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultFTPPort)
+	}
+
+	d := f.ftpDialer()
+
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ftpConn{Conn: textproto.NewConn(conn), host: u.Hostname(), dialer: d}
+
+	user, password := defaultFTPUser, defaultFTPPassword
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	if strings.ContainsAny(user+password, "\r\n") {
+		conn.Close()
+		return nil, errors.New("Invalid FTP credentials, control characters are not allowed")
+	}
+
+	if err = c.login(user, password); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ftpDialer returns the dialer of the control and data connections, from Options.LocalAddr if set
+func (f *File) ftpDialer() *net.Dialer {
+
+	d := new(net.Dialer)
+
+	if addr := f.options.localAddr(); addr != nil {
+		d.LocalAddr = addr
+	}
+
+	return d
+}
+
+// login greets the server, logs in and switches to binary transfers
+func (c *ftpConn) login(user, password string) error {
+
+	if _, _, err := c.ReadResponse(220); err != nil {
+		return ftpErr(err, 220)
+	}
+
+	code, _, err := c.cmd("USER "+user, 230, 331)
+	if err != nil {
+		return err
+	}
+
+	// 230 is logged in without a password
+	if code == 331 {
+		if _, _, err = c.cmd("PASS "+password, 230, 202); err != nil {
+			return err
+		}
+	}
+
+	_, _, err = c.cmd("TYPE I", 200)
+	return err
+}
+
+// retr opens a passive data connection and starts retrieving path over it
+func (c *ftpConn) retr(ctx context.Context, path string) (net.Conn, error) {
+
+	_, msg, err := c.cmd("PASV", 227)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := pasvPort(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// the advertised address is ignored, it's often private when the server is behind NAT
+	data, err := c.dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err = c.cmd("RETR "+path, 150, 125); err != nil {
+		data.Close()
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// cmd sends the command line and reads the response, returning an *InvalidResponseCode
+// error when the response code is not one of those expected.
+func (c *ftpConn) cmd(line string, expected ...int) (int, string, error) {
+
+	id, err := c.Cmd("%s", line)
+	if err != nil {
+		return 0, "", err
+	}
+
+	c.StartResponse(id)
+	defer c.EndResponse(id)
+
+	code, msg, err := c.ReadResponse(0)
+	if err != nil {
+		return code, msg, ftpErr(err, expected[0])
+	}
+
+	for _, e := range expected {
+		if code == e {
+			return code, msg, nil
+		}
+	}
+
+	return code, msg, &InvalidResponseCode{got: code, expected: expected[0]}
+}
+
+// pasvPort returns the data port of a PASV response, eg. "Entering Passive Mode (127,0,0,1,4,1)"
+func pasvPort(msg string) (int, error) {
+
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return 0, fmt.Errorf("Invalid PASV response '%s'", msg)
+	}
+
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return 0, fmt.Errorf("Invalid PASV response '%s'", msg)
+	}
+
+	p1, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("Invalid PASV response '%s'", msg)
+	}
+
+	return p1<<8 | p2, nil
+}
+
+// ftpErr converts the protocol error of an unexpected response to an *InvalidResponseCode
+func ftpErr(err error, expected int) error {
+
+	if e, ok := err.(*textproto.Error); ok {
+		return &InvalidResponseCode{got: e.Code, expected: expected}
+	}
+
+	return err
+}
+
+// closeOnDone closes c if the context is done before stop is called, interrupting any blocked I/O
+func closeOnDone(ctx context.Context, c io.Closer) (stop func()) {
+
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,416 @@
+package download
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ftpServer is a minimal FTP server serving files for the tests
+type ftpServer struct {
+	ln       net.Listener
+	files    map[string][]byte
+	modTime  time.Time
+	noSize   bool
+	mu       sync.Mutex
+	commands []string
+	remotes  []string
+}
+
+func newFTPServer(t *testing.T, files map[string][]byte) *ftpServer {
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &ftpServer{ln: ln, files: files, modTime: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *ftpServer) URL() string {
+	return "ftp://" + s.ln.Addr().String()
+}
+
+func (s *ftpServer) Close() {
+	s.ln.Close()
+}
+
+func (s *ftpServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// remote records the ip address a connection was made from
+func (s *ftpServer) remote(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remotes = append(s.remotes, conn.RemoteAddr().(*net.TCPAddr).IP.String())
+}
+
+func (s *ftpServer) serve(conn net.Conn) {
+
+	defer conn.Close()
+
+	s.remote(conn)
+
+	c := textproto.NewConn(conn)
+
+	var data net.Listener
+	var offset int64
+
+	c.PrintfLine("220 ready")
+
+	for {
+
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+
+		cmd, arg := line, ""
+		if i := strings.Index(line, " "); i >= 0 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+
+		b, exists := s.files[arg]
+
+		switch cmd {
+		case "USER":
+			c.PrintfLine("331 password required")
+		case "PASS":
+			c.PrintfLine("230 logged in")
+		case "TYPE":
+			c.PrintfLine("200 binary")
+		case "SIZE":
+			switch {
+			case s.noSize:
+				c.PrintfLine("502 not implemented")
+			case !exists:
+				c.PrintfLine("550 not found")
+			default:
+				c.PrintfLine("213 %d", len(b))
+			}
+		case "MDTM":
+			c.PrintfLine("213 %s", s.modTime.Format(mdtmFormat))
+		case "REST":
+			offset, _ = strconv.ParseInt(arg, 10, 64)
+			c.PrintfLine("350 restarting at %d", offset)
+		case "PASV":
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				return
+			}
+			port := data.Addr().(*net.TCPAddr).Port
+			c.PrintfLine("227 Entering Passive Mode (10,0,0,1,%d,%d)", port>>8, port&0xff)
+		case "RETR":
+			if !exists {
+				data.Close()
+				c.PrintfLine("550 not found")
+				continue
+			}
+
+			c.PrintfLine("150 opening data connection")
+
+			dc, err := data.Accept()
+			data.Close()
+			if err != nil {
+				return
+			}
+
+			s.remote(dc)
+
+			w := bufio.NewWriter(dc)
+			w.Write(b[offset:])
+			w.Flush()
+			dc.Close()
+
+			offset = 0
+			c.PrintfLine("226 transfer complete")
+		default:
+			c.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestFTP(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	server := newFTPServer(t, map[string][]byte{"pub/file.txt": content})
+	defer server.Close()
+
+	for _, noSize := range []bool{false, true} {
+
+		server.noSize = noSize
+
+		f, err := Open(server.URL()+"/pub/file.txt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fi.Name() != "file.txt" || fi.Size() != int64(len(content)) {
+			t.Fatalf("Expected 'file.txt' of size '%d' got '%s' of size '%d'", len(content), fi.Name(), fi.Size())
+		}
+
+		if !fi.ModTime().Equal(server.modTime) {
+			t.Fatalf("Expected mod time '%s' got '%s'", server.modTime, fi.ModTime())
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+	}
+
+	server.noSize = false
+
+	_, err := Open(server.URL()+"/pub/missing.txt", nil)
+	if _, ok := err.(*InvalidResponseCode); !ok {
+		t.Fatalf("Expected error to be of type *InvalidResponseCode got '%v'", err)
+	}
+
+	expected := "Invalid response code, received '550' expected '150'"
+	if err.Error() != expected {
+		t.Fatalf("Expected '%s' got '%s'", expected, err)
+	}
+}
+
+func TestFTPResume(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	server := newFTPServer(t, map[string][]byte{"file.txt": content})
+	defer server.Close()
+
+	url := server.URL() + "/file.txt"
+	dir := (&File{url: url, options: new(Options)}).resumeDir()
+
+	if err := os.MkdirAll(dir, fileMode); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, partialName), content[:4000], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	var restarted bool
+
+	for _, cmd := range server.received() {
+		if cmd == fmt.Sprintf("REST %d", 4000) {
+			restarted = true
+		}
+	}
+
+	if !restarted {
+		t.Fatalf("Expected the download to be resumed got commands '%v'", server.received())
+	}
+}
+
+func TestFTPInvalidPath(t *testing.T) {
+
+	server := newFTPServer(t, map[string][]byte{"file.txt": []byte("content")})
+	defer server.Close()
+
+	_, err := Open(server.URL()+"/file.txt%0D%0ADELE%20file.txt", nil)
+	if err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+
+	expected := `Invalid FTP path "/file.txt\r\nDELE file.txt", control characters are not allowed`
+	if err.Error() != expected {
+		t.Fatalf("Expected '%s' got '%s'", expected, err)
+	}
+
+	if cmds := server.received(); len(cmds) > 0 {
+		t.Fatalf("Expected no commands got '%v'", cmds)
+	}
+}
+
+func TestFTPUnsupported(t *testing.T) {
+
+	server := newFTPServer(t, map[string][]byte{"file.txt": []byte("content")})
+	defer server.Close()
+
+	url := server.URL() + "/file.txt"
+	ctx := context.Background()
+
+	tests := []struct {
+		feature string
+		call    func() error
+	}{
+		{
+			feature: "Suffix",
+			call: func() error {
+				_, err := Open(url, &Options{Suffix: 3})
+				return err
+			},
+		},
+		{
+			feature: "OpenRanges",
+			call: func() error {
+				_, err := OpenRanges(ctx, url, [][2]int64{{0, 2}}, nil)
+				return err
+			},
+		},
+		{
+			feature: "OpenMirrors",
+			call: func() error {
+				_, err := OpenMirrors(ctx, []string{"http://localhost/file.txt", url}, nil)
+				return err
+			},
+		},
+		{
+			feature: "Equal",
+			call: func() error {
+				_, err := Equal(ctx, "http://localhost/file.txt", url, nil)
+				return err
+			},
+		},
+		{
+			feature: "RefreshStat",
+			call: func() error {
+				_, err := (&File{url: url, options: new(Options)}).RefreshStat(ctx)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+
+		err := tt.call()
+		if _, ok := err.(*UnsupportedProtocol); !ok {
+			t.Fatalf("%s: Expected error to be of type *UnsupportedProtocol got '%v'", tt.feature, err)
+		}
+
+		expected := fmt.Sprintf("Unsupported protocol 'ftp', %s requires http or https", tt.feature)
+		if err.Error() != expected {
+			t.Fatalf("Expected '%s' got '%s'", expected, err)
+		}
+	}
+
+	if cmds := server.received(); len(cmds) > 0 {
+		t.Fatalf("Expected no commands got '%v'", cmds)
+	}
+}
+
+func TestFTPLocalAddr(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := newFTPServer(t, map[string][]byte{"file.txt": content})
+	defer server.Close()
+
+	// any 127/8 address is the loopback interface
+	f, err := Open(server.URL()+"/file.txt", &Options{LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	server.mu.Lock()
+	remotes := server.remotes
+	server.mu.Unlock()
+
+	if len(remotes) != 2 {
+		t.Fatalf("Expected control and data connections got '%v'", remotes)
+	}
+
+	for _, ip := range remotes {
+		if ip != "127.0.0.2" {
+			t.Fatalf("Expected connections from '127.0.0.2' got '%v'", remotes)
+		}
+	}
+}
+
+func TestFTPOpenSizeAndLazy(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := newFTPServer(t, map[string][]byte{"file.txt": content})
+	defer server.Close()
+
+	url := server.URL() + "/file.txt"
+
+	open := []func() (*File, error){
+		func() (*File, error) {
+			return OpenSize(context.Background(), url, int64(len(content)), nil)
+		},
+		func() (*File, error) {
+			return OpenLazy(context.Background(), url, nil)
+		},
+	}
+
+	for i, fn := range open {
+
+		f, err := fn()
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%d: Downloaded content does not match", i)
+		}
+	}
+}
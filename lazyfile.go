@@ -0,0 +1,111 @@
+package download
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+var _ chunkReader = (*lazyFile)(nil)
+
+// lazyFile is a chunk file that is only opened while it's being read, keeping the
+// number of open file descriptors bounded for ranged downloads with many chunks
+type lazyFile struct {
+	name string
+	fh   *os.File
+	pos  int64
+}
+
+// Name returns the name of the file
+func (l *lazyFile) Name() string {
+	return l.name
+}
+
+// Read opens the file if necessary and reads up to len(b) bytes from it,
+// the file is closed once the end is reached.
+func (l *lazyFile) Read(b []byte) (int, error) {
+
+	if l.fh == nil {
+
+		fh, err := os.Open(l.name)
+		if err != nil {
+			return 0, err
+		}
+
+		if _, err = fh.Seek(l.pos, io.SeekStart); err != nil {
+			fh.Close()
+			return 0, err
+		}
+
+		l.fh = fh
+	}
+
+	n, err := l.fh.Read(b)
+	l.pos += int64(n)
+
+	if err == io.EOF {
+		l.fh.Close()
+		l.fh = nil
+	}
+
+	return n, err
+}
+
+// ReadAt reads len(b) bytes from the file starting at byte offset off
+func (l *lazyFile) ReadAt(b []byte, off int64) (int, error) {
+
+	if l.fh != nil {
+		return l.fh.ReadAt(b, off)
+	}
+
+	fh, err := os.Open(l.name)
+	if err != nil {
+		return 0, err
+	}
+	defer fh.Close()
+
+	return fh.ReadAt(b, off)
+}
+
+// Seek sets the offset for the next Read, the file is
+// closed and reopened at the new offset by the next Read
+func (l *lazyFile) Seek(offset int64, whence int) (int64, error) {
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += l.pos
+	case io.SeekEnd:
+
+		fi, err := os.Stat(l.name)
+		if err != nil {
+			return 0, err
+		}
+
+		offset += fi.Size()
+	default:
+		return 0, errors.New("download.lazyFile.Seek: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("download.lazyFile.Seek: negative position")
+	}
+
+	l.Close()
+	l.pos = offset
+
+	return offset, nil
+}
+
+// Close closes the file if it's open
+func (l *lazyFile) Close() error {
+
+	if l.fh == nil {
+		return nil
+	}
+
+	err := l.fh.Close()
+	l.fh = nil
+
+	return err
+}
@@ -0,0 +1,115 @@
+package download
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openFiles returns the number of files within dir opened by this process
+func openFiles(t *testing.T, dir string) int {
+
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("open file descriptors not available")
+	}
+
+	var count int
+
+	for _, fd := range fds {
+
+		path, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err != nil {
+			continue
+		}
+
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestLazyFileDescriptors(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/many-chunks.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "many-chunks.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	options := &Options{
+		Concurrency: func(size int64) int {
+			return 200
+		},
+		MinSizeForRanges: -1,
+	}
+
+	f, err := Open(server.URL+"/testdata/many-chunks.txt", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if n := openFiles(t, f.dir); n != 0 {
+		t.Fatalf("Expected no open chunk files after download got '%d'", n)
+	}
+
+	var buf bytes.Buffer
+	b := make([]byte, 7)
+
+	for {
+
+		n, err := f.Read(b)
+		buf.Write(b[:n])
+
+		if open := openFiles(t, f.dir); open > 1 {
+			t.Fatalf("Expected at most 1 open chunk file got '%d'", open)
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	// random access after reading
+	if _, err = f.Seek(5005, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = io.ReadFull(f, b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content[5005:5012]) {
+		t.Fatal("Content after seek does not match")
+	}
+
+	if _, err = f.ReadAt(b, 9995); err != io.EOF {
+		t.Fatalf("Expected '%v' got '%v'", io.EOF, err)
+	}
+
+	if open := openFiles(t, f.dir); open > 1 {
+		t.Fatalf("Expected at most 1 open chunk file got '%d'", open)
+	}
+}
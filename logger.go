@@ -0,0 +1,27 @@
+package download
+
+import "log"
+
+// Logger is the interface of the Options.Logger, it's satisfied by *log.Logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logf logs the notice or warning to the Options.Logger, or the standard logger when there's none
+func (f *File) logf(format string, v ...interface{}) {
+
+	if f.options.Logger != nil {
+		f.options.Logger.Printf(format, v...)
+		return
+	}
+
+	log.Printf(format, v...)
+}
+
+// debugf logs the internal event to the Options.Logger, the events aren't logged without one
+func (f *File) debugf(format string, v ...interface{}) {
+
+	if f.options.Logger != nil {
+		f.options.Logger.Printf("debug: "+format, v...)
+	}
+}
@@ -0,0 +1,112 @@
+package download
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	var failed int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("X-Max-Connections", "many")
+
+		// the first chunk fails once
+		if r.Header.Get("Range") == "bytes=0-2499" && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		http.ServeContent(w, r, "logged.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/logged.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	var buf bytes.Buffer
+
+	f, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		MaxRetries:       1,
+		MaxConnsHeader:   "X-Max-Connections",
+		Logger:           log.New(&buf, "", 0),
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	logged := buf.String()
+
+	for _, expected := range []string{
+		"notice: invalid 'X-Max-Connections' header value 'many', ignoring.\n",
+		"debug: HEAD response size '10000' rangeable 'true' etag ''\n",
+		"debug: ranged download of '4' chunks, '4' at once\n",
+		"debug: chunk 0 started, bytes '0-2499'\n",
+		"debug: chunk 0 attempt 1 failed, retrying: Invalid response code, received '503' expected '206'\n",
+		"debug: chunk 0 finished\n",
+		"debug: chunk 3 finished\n",
+	} {
+		if !strings.Contains(logged, expected) {
+			t.Fatalf("Expected '%s' to be logged got '%s'", strings.TrimSpace(expected), logged)
+		}
+	}
+
+	// a single stream download
+	buf.Reset()
+
+	f, err = Open(url, &Options{Logger: log.New(&buf, "", 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if !strings.Contains(buf.String(), "debug: single stream download: size '10000' below MinSizeForRanges") {
+		t.Fatalf("Expected the range decision to be logged got '%s'", buf.String())
+	}
+}
+
+func TestLoggerInvalidLocalAddr(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "localaddr.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/localaddr.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	var buf bytes.Buffer
+
+	// not a TCP address, the download goes ahead from the default address
+	f, err := Open(url, &Options{
+		LocalAddr: &net.UnixAddr{Name: "/tmp/local.sock", Net: "unix"},
+		Logger:    log.New(&buf, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if !strings.Contains(buf.String(), "notice: invalid LocalAddr '/tmp/local.sock', ignoring") {
+		t.Fatalf("Expected the invalid LocalAddr to be logged got '%s'", buf.String())
+	}
+}
@@ -0,0 +1,23 @@
+package download
+
+import "bytes"
+
+var _ chunkReader = (*memChunk)(nil)
+
+// memChunk is a chunk downloaded into memory
+type memChunk struct {
+	*bytes.Reader
+	b []byte
+}
+
+// newMemChunk returns a memChunk reading b
+func newMemChunk(b []byte) *memChunk {
+	return &memChunk{Reader: bytes.NewReader(b), b: b}
+}
+
+// Close drops the downloaded content
+func (m *memChunk) Close() error {
+	m.Reset(nil)
+	m.b = nil
+	return nil
+}
@@ -0,0 +1,67 @@
+package download
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInMemory(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "memory.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/memory.txt"
+
+	for _, minSize := range []int64{0, -1} {
+
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges: minSize,
+			InMemory:         true,
+			TempFileName: func(dir string) (string, error) {
+				t.Fatal("Expected no temp file to be created")
+				return "", nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if files := f.TempFiles(); len(files) != 0 {
+			t.Fatalf("%d: Expected no temp files got '%v'", minSize, files)
+		}
+
+		if _, err = os.Stat(f.resumeDir()); !os.IsNotExist(err) {
+			t.Fatalf("%d: Expected no resume directory got '%v'", minSize, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%d: Downloaded content does not match", minSize)
+		}
+
+		b = make([]byte, 10)
+
+		if _, err = f.ReadAt(b, 495); err != nil || string(b) != "5678901234" {
+			t.Fatalf("%d: Expected '%s' got '%s' '%v'", minSize, "5678901234", b, err)
+		}
+
+		if err = f.Close(); err != nil {
+			t.Fatalf("%d: %s", minSize, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// OpenMirrors downloads and opens the file served identically by each of the urls, eg. by several
+// CDNs. The size and range support are determined by the first url, the chunks are then spread
+// across all of the urls each failing over to the next url when a request fails; set
+// Options.Checksum to verify that the mirrors really did serve the same content.
+// The context provided must be non-nil
+func OpenMirrors(ctx context.Context, urls []string, options *Options) (*File, error) {
+
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	if len(urls) == 0 {
+		return nil, errors.New("Invalid mirrors, at least one url is required")
+	}
+
+	for _, url := range urls {
+		if err := httpOnly(url, "OpenMirrors"); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := newFile(urls[0], options)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mirrors = urls[1:]
+
+	return f.opened(ctx, f.within(ctx, f.open))
+}
+
+// fetchMirrors fetches the remaining start-end bytes of the chunk starting at chunkStart, trying
+// each mirror in turn from the chunk's own, so that the chunks are spread across the mirrors,
+// until one succeeds. It returns the number of bytes written to w and the last error.
+func (f *File) fetchMirrors(ctx context.Context, idx int, chunkStart, start, end int64, w io.Writer) (int64, error) {
+
+	urls := append([]string{f.requestURL()}, f.mirrors...)
+
+	var written int64
+	var err error
+
+	for i := 0; i < len(urls); i++ {
+
+		rawurl := urls[(idx+i)%len(urls)]
+
+		var n int64
+
+		n, err = f.fetchPartial(ctx, idx, chunkStart, start+written, end, w, rawurl)
+		written += n
+
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+
+		if i < len(urls)-1 {
+			f.debugf("chunk %d failed from '%s', failing over: %s\n", idx, rawurl, err)
+		}
+	}
+
+	return written, err
+}
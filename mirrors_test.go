@@ -0,0 +1,83 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOpenMirrors(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	var primaryChunks, mirrorChunks int32
+
+	// the primary answers the HEAD but fails every chunk
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&primaryChunks, 1)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("ETag", `"primary"`)
+		http.ServeContent(w, r, "mirrored.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer primary.Close()
+
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&mirrorChunks, 1)
+		}
+
+		w.Header().Set("ETag", `"mirror"`)
+		http.ServeContent(w, r, "mirrored.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer mirror.Close()
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		NoResume:         true,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	}
+
+	f, err := OpenMirrors(context.Background(), []string{primary.URL + "/mirrored.txt", mirror.URL + "/mirrored.txt"}, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	// the chunks are spread across both, those started on the primary failing over
+	if primaryChunks != 2 || mirrorChunks != 4 {
+		t.Fatalf("Expected 2 primary and 4 mirror chunk requests got '%d' and '%d'", primaryChunks, mirrorChunks)
+	}
+
+	_, err = OpenMirrors(context.Background(), nil, nil)
+	if err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+
+	expected := "Invalid mirrors, at least one url is required"
+	if err.Error() != expected {
+		t.Fatalf("Expected '%s' got '%s'", expected, err)
+	}
+}
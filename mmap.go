@@ -0,0 +1,89 @@
+package download
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+var _ chunkReader = (*mmapChunk)(nil)
+
+// mmapChunk is a downloaded chunk file mapped into memory
+type mmapChunk struct {
+	*bytes.Reader
+	name string
+	b    []byte
+}
+
+// Name returns the name of the mapped file
+func (m *mmapChunk) Name() string {
+	return m.name
+}
+
+// Close unmaps the file, the file itself is removed with the File's directory
+func (m *mmapChunk) Close() error {
+
+	b := m.b
+	if b == nil {
+		return nil
+	}
+
+	m.Reset(nil)
+	m.b = nil
+
+	return munmap(b)
+}
+
+// mmap maps the downloaded chunk files into memory so that reads are served from the
+// mapping rather than by a syscall per read. Chunks that are empty, in memory, or on
+// platforms without mmap support are left as they are.
+func (f *File) mmap() error {
+
+	for i := 0; i < len(f.readers); i++ {
+
+		r, ok := f.readers[i].(namer)
+		if !ok {
+			continue
+		}
+
+		if _, ok = r.(*mmapChunk); ok {
+			continue
+		}
+
+		b, err := mmapFile(r.Name())
+		if err != nil {
+			return err
+		}
+
+		if b == nil {
+			continue
+		}
+
+		f.readers[i].Close()
+		f.readers[i] = &mmapChunk{Reader: bytes.NewReader(b), name: r.Name(), b: b}
+	}
+
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+// mmapFile maps the named file read only, returning nil when it's empty or mapping isn't supported
+func mmapFile(name string) ([]byte, error) {
+
+	fh, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close() // the mapping remains valid after the file is closed
+
+	fi, err := fh.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.Size() == 0 {
+		return nil, nil
+	}
+
+	return mmapFd(fh, fi.Size())
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package download
+
+import "os"
+
+// mmapFd doesn't map the file as mmap isn't supported, leaving the chunk to be read as a file
+func mmapFd(fh *os.File, size int64) ([]byte, error) {
+	return nil, nil
+}
+
+// munmap does nothing as nothing is mapped
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build unix
+
+package download
+
+import (
+	"bytes"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMmap(t *testing.T) {
+
+	content := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "mapped.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/mapped.bin"
+	rnd := rand.New(rand.NewSource(2))
+
+	for _, minSize := range []int64{0, -1} {
+
+		f, err := Open(url, &Options{MinSizeForRanges: minSize, Mmap: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < len(f.readers); i++ {
+			if _, ok := f.readers[i].(*mmapChunk); !ok {
+				t.Fatalf("Expected reader to be of type *mmapChunk got '%T'", f.readers[i])
+			}
+		}
+
+		for i := 0; i < 1000; i++ {
+
+			off := rnd.Int63n(int64(len(content)))
+			b := make([]byte, rnd.Intn(64<<10))
+
+			n, err := f.ReadAt(b, off)
+			if err != nil && n == len(b) {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b[:n], content[off:off+int64(n)]) {
+				t.Fatalf("ReadAt '%d' of '%d' bytes does not match", off, len(b))
+			}
+		}
+
+		files := f.TempFiles()
+
+		if err = f.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range files {
+			if _, err = os.Stat(name); !os.IsNotExist(err) {
+				t.Fatalf("Expected '%s' to be removed got '%v'", name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package download
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFd maps size bytes of the open file read only
+func mmapFd(fh *os.File, size int64) ([]byte, error) {
+
+	b, err := syscall.Mmap(int(fh.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: fh.Name(), Err: err}
+	}
+
+	return b, nil
+}
+
+// munmap unmaps b returned by mmapFd
+func munmap(b []byte) error {
+	return syscall.Munmap(b)
+}
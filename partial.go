@@ -0,0 +1,64 @@
+package download
+
+import "io"
+
+// Range is an inclusive byte range of the file
+type Range struct {
+	Start int64
+	End   int64
+}
+
+// Progress returns the number of bytes downloaded and the size of the file, eg. to inspect
+// the File returned along with a *Canceled or *DeadlineExceeded error by OpenContext.
+func (f *File) Progress() (downloaded, size int64) {
+
+	for i := 0; i < len(f.chunks); i++ {
+		downloaded += f.chunkDownloaded(i)
+	}
+
+	return downloaded, f.size
+}
+
+// MissingRanges returns the byte ranges of the file not yet downloaded,
+// empty when the download is complete.
+func (f *File) MissingRanges() []Range {
+
+	var missing []Range
+
+	for i := 0; i < len(f.chunks); i++ {
+
+		c := f.chunks[i]
+
+		if start := c.start + f.chunkDownloaded(i); start <= c.end {
+			missing = append(missing, Range{Start: start, End: c.end})
+		}
+	}
+
+	return missing
+}
+
+// chunkDownloaded returns the number of bytes downloaded of chunk i,
+// leaving the read position of it's reader unchanged
+func (f *File) chunkDownloaded(i int) int64 {
+
+	r := f.readers[i]
+	if r == nil {
+		return 0
+	}
+
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+
+	n, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0
+	}
+
+	if _, err = r.Seek(pos, io.SeekStart); err != nil {
+		return 0
+	}
+
+	return n
+}
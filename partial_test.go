@@ -0,0 +1,125 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPartialOnCancel(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var stall int32 = 1
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		rng := r.Header.Get("Range")
+
+		if rng == "" || atomic.LoadInt32(&stall) == 0 || rng == "bytes=0-249" {
+			http.ServeContent(w, r, "partial.txt", time.Time{}, bytes.NewReader(content))
+			if rng == "bytes=0-249" {
+				wg.Done()
+			}
+			return
+		}
+
+		var start, end int64
+		if _, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// the other chunks stall after their first bytes
+		w.Header().Set("Content-Range", "bytes "+rng[len("bytes="):]+"/1000")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(content[start : start+50])
+		w.(http.Flusher).Flush()
+		wg.Done()
+
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	url := server.URL + "/partial.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		wg.Wait()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	f, err := OpenContext(ctx, url, options)
+	if _, ok := err.(*Canceled); !ok {
+		t.Fatalf("Expected error to be of type *Canceled got '%v'", err)
+	}
+
+	if f == nil {
+		t.Fatal("Expected the partially downloaded File got <nil>")
+	}
+
+	downloaded, size := f.Progress()
+	if size != int64(len(content)) || downloaded < 250 || downloaded >= size {
+		t.Fatalf("Expected partial progress of '%d' bytes got '%d' of '%d'", len(content), downloaded, size)
+	}
+
+	missing := f.MissingRanges()
+	if len(missing) == 0 || missing[0].Start < 250 {
+		t.Fatalf("Expected the first chunk complete and the rest missing got '%v'", missing)
+	}
+
+	for _, r := range missing {
+		downloaded += (r.End - r.Start) + 1
+	}
+
+	if downloaded != size {
+		t.Fatalf("Expected downloaded and missing bytes to add up to '%d' got '%d'", size, downloaded)
+	}
+
+	if err = f.GracefulClose(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the download resumes from the inspected chunks
+	atomic.StoreInt32(&stall, 0)
+
+	f, err = Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if missing = f.MissingRanges(); len(missing) != 0 {
+		t.Fatalf("Expected nothing missing got '%v'", missing)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
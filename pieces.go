@@ -0,0 +1,133 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// verifyPieces verifies each Options.PieceLength piece of the downloaded file against it's
+// SHA-1 hash in Options.PieceHashes, a corrupt piece is downloaded again once before
+// failing with a *PieceMismatch error.
+func (f *File) verifyPieces(ctx context.Context) error {
+
+	length := f.options.PieceLength
+	if length <= 0 {
+		return fmt.Errorf("Invalid piece length '%d'", length)
+	}
+
+	if pieces := (f.size + length - 1) / length; int64(len(f.options.PieceHashes)) != pieces {
+		return fmt.Errorf("Invalid number of piece hashes, received '%d' expected '%d'", len(f.options.PieceHashes), pieces)
+	}
+
+	b := make([]byte, length)
+
+	for i, expected := range f.options.PieceHashes {
+
+		start := int64(i) * length
+		end := start + length - 1
+		if end >= f.size {
+			end = f.size - 1
+		}
+
+		piece := b[:(end-start)+1]
+
+		if _, err := f.ReadAt(piece, start); err != nil && err != io.EOF {
+			return err
+		}
+
+		if got := sha1.Sum(piece); bytes.Equal(got[:], expected) {
+			continue
+		}
+
+		// only the corrupt piece is downloaded again
+		if err := f.fetchPiece(ctx, piece, start, end); err != nil {
+			return err
+		}
+
+		if err := f.writeAt(piece, start); err != nil {
+			return err
+		}
+
+		if got := sha1.Sum(piece); !bytes.Equal(got[:], expected) {
+			return &PieceMismatch{piece: i, start: start, end: end, expected: expected, got: got[:]}
+		}
+	}
+
+	return nil
+}
+
+// fetchPiece downloads the inclusive byte range start-end into b
+func (f *File) fetchPiece(ctx context.Context, b []byte, start, end int64) error {
+
+	req, err := f.newRequest(ctx, http.MethodGet, f.requestURL())
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return &InvalidResponseCode{got: resp.StatusCode, expected: http.StatusPartialContent}
+	}
+
+	_, err = io.ReadFull(f.limitRate(ctx, resp.Body), b)
+	return err
+}
+
+// writeAt overwrites the downloaded file(s) with b starting at byte offset off
+func (f *File) writeAt(b []byte, off int64) error {
+
+	var n int
+
+	for i := 0; i < len(f.chunks) && n < len(b); i++ {
+
+		c := f.chunks[i]
+		pos := off + int64(n)
+
+		if pos > c.end {
+			continue
+		}
+
+		want := len(b) - n
+		if remaining := c.end - pos + 1; remaining < int64(want) {
+			want = int(remaining)
+		}
+
+		switch r := f.readers[i].(type) {
+		case *memChunk:
+			copy(r.b[pos-c.start:], b[n:n+want])
+		case namer:
+			fh, err := os.OpenFile(r.Name(), os.O_WRONLY, 0)
+			if err != nil {
+				return err
+			}
+
+			_, err = fh.WriteAt(b[n:n+want], pos-c.start)
+			fh.Close()
+
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("Unable to write chunk '%d'", i)
+		}
+
+		n += want
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package download
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPieceHashes(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	corrupt := make([]byte, len(content))
+	copy(corrupt, content)
+	corrupt[150] = 'x'
+
+	var hashes [][]byte
+
+	for i := 0; i < len(content); i += 300 {
+
+		end := i + 300
+		if end > len(content) {
+			end = len(content)
+		}
+
+		h := sha1.Sum(content[i:end])
+		hashes = append(hashes, h[:])
+	}
+
+	var m sync.Mutex
+	var pieces []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		b := corrupt
+
+		// the re-downloaded piece is correct unless always corrupt
+		if rng := r.Header.Get("Range"); rng == "bytes=0-299" && r.URL.Query().Get("always") == "" {
+			m.Lock()
+			pieces = append(pieces, rng)
+			m.Unlock()
+			b = content
+		}
+
+		http.ServeContent(w, r, "pieces.txt", time.Time{}, bytes.NewReader(b))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		options *Options
+	}{
+		{name: "single", options: &Options{}},
+		{name: "ranged", options: &Options{MinSizeForRanges: -1, Concurrency: func(size int64) int { return 3 }}},
+		{name: "memory", options: &Options{MinSizeForRanges: -1, InMemory: true}},
+	}
+
+	for _, tt := range tests {
+
+		url := server.URL + "/" + tt.name + "/pieces.txt"
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		pieces = nil
+		tt.options.PieceHashes = hashes
+		tt.options.PieceLength = 300
+
+		f, err := Open(url, tt.options)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%s: Downloaded content does not match", tt.name)
+		}
+
+		// only the corrupt piece is downloaded again
+		if len(pieces) != 1 {
+			t.Fatalf("%s: Expected the corrupt piece to be downloaded again got '%v'", tt.name, pieces)
+		}
+
+		_, err = Open(url+"?always=1", tt.options)
+		if _, ok := err.(*PieceMismatch); !ok {
+			t.Fatalf("%s: Expected error to be of type *PieceMismatch got '%v'", tt.name, err)
+		}
+	}
+
+	// the hashes must cover the file
+	_, err := Open(server.URL+"/pieces.txt", &Options{PieceHashes: hashes[:1], PieceLength: 300})
+	if err == nil || err.Error() != "Invalid number of piece hashes, received '1' expected '4'" {
+		t.Fatalf("Expected invalid number of piece hashes got '%v'", err)
+	}
+}
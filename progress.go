@@ -0,0 +1,135 @@
+package download
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const defaultProgressInterval = 100 * time.Millisecond
+
+// throttle limits progress notifications to at most one per interval,
+// apart from forced ones such as the final notification
+type throttle struct {
+	interval time.Duration
+	last     time.Time
+	sent     int64
+}
+
+// allow returns if the progress of read bytes should be notified
+func (t *throttle) allow(read int64, force bool) bool {
+
+	if read == t.sent {
+		return false
+	}
+
+	now := time.Now()
+
+	if !force && now.Sub(t.last) < t.interval {
+		return false
+	}
+
+	t.last, t.sent = now, read
+
+	return true
+}
+
+// aggregate sums the progress of all the chunks of a download
+type aggregate struct {
+	sync.Mutex
+	fn       ProgressFn
+	read     int64
+	size     int64
+	throttle throttle
+}
+
+// add reports n more bytes of the download as read
+func (a *aggregate) add(n int64) {
+
+	if n <= 0 {
+		return
+	}
+
+	a.Lock()
+	a.read += n
+	if a.throttle.allow(a.read, a.read == a.size) {
+		a.fn(0, a.read, a.size)
+	}
+	a.Unlock()
+}
+
+// flush notifies any progress not yet notified
+func (a *aggregate) flush() {
+
+	a.Lock()
+	if a.throttle.allow(a.read, true) {
+		a.fn(0, a.read, a.size)
+	}
+	a.Unlock()
+}
+
+// progressReader reports the number of bytes read from the underlying io.Reader
+type progressReader struct {
+	r      io.Reader
+	report func(n int64)
+	flush  func()
+}
+
+// Read reads from the underlying io.Reader reporting the number of bytes read
+func (p *progressReader) Read(b []byte) (int, error) {
+
+	n, err := p.r.Read(b)
+	p.report(int64(n))
+
+	if err == io.EOF && p.flush != nil {
+		p.flush()
+	}
+
+	return n, err
+}
+
+// newAggregate returns the aggregate reporting to the Options.Progress function
+func (f *File) newAggregate() *aggregate {
+	return &aggregate{fn: f.options.Progress, size: f.size, throttle: throttle{interval: f.options.progressInterval()}}
+}
+
+// trackProgress returns r reporting it's progress to the Options.Progress function, done
+// is the number of bytes of the download, of the given size, that were already downloaded.
+func (f *File) trackProgress(idx int, done, size int64, r io.Reader) io.Reader {
+
+	if f.options.Progress == nil {
+		return r
+	}
+
+	// the bytes already downloaded are added to the aggregate once by the caller,
+	// when the size is unknown the end of the download is only known at EOF
+	if f.aggregate != nil {
+
+		p := &progressReader{r: r, report: f.aggregate.add}
+		if f.aggregate.size < 0 {
+			p.flush = f.aggregate.flush
+		}
+
+		return p
+	}
+
+	read := done
+	t := throttle{interval: f.options.progressInterval(), sent: done}
+
+	return &progressReader{
+		r: r,
+		report: func(n int64) {
+			if n > 0 {
+				read += n
+				if t.allow(read, read == size) {
+					f.options.Progress(idx, read, size)
+				}
+			}
+		},
+		flush: func() {
+			if t.allow(read, true) {
+				f.options.Progress(idx, read, size)
+			}
+		},
+	}
+}
@@ -0,0 +1,167 @@
+package download
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "progress.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/progress.txt"
+	total := int64(len(content))
+
+	type call struct {
+		download int
+		read     int64
+		size     int64
+	}
+
+	var m sync.Mutex
+	var calls []call
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+		Progress: func(download int, read, size int64) {
+			m.Lock()
+			calls = append(calls, call{download: download, read: read, size: size})
+			m.Unlock()
+		},
+	}
+
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// each chunk reaches it's own size
+	done := make(map[int]bool)
+
+	for _, c := range calls {
+
+		if c.size != total/4 {
+			t.Fatalf("Expected chunk size '%d' got '%d'", total/4, c.size)
+		}
+
+		if c.read == c.size {
+			done[c.download] = true
+		}
+	}
+
+	if len(done) != 4 {
+		t.Fatalf("Expected '%d' chunks to complete got '%d'", 4, len(done))
+	}
+
+	options.AggregateProgress = true
+	calls = nil
+
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	f, err = Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var last int64
+	var complete int
+
+	for _, c := range calls {
+
+		if c.download != 0 || c.size != total {
+			t.Fatalf("Expected aggregated download '0' of size '%d' got '%d' of size '%d'", total, c.download, c.size)
+		}
+
+		if c.read <= last {
+			t.Fatalf("Expected aggregated progress to increase, received '%d' after '%d'", c.read, last)
+		}
+
+		if c.read == total {
+			complete++
+		}
+
+		last = c.read
+	}
+
+	if last != total || complete != 1 {
+		t.Fatalf("Expected aggregated progress to reach '%d' exactly once, reached '%d' '%d' time(s)", total, last, complete)
+	}
+}
+
+func TestProgressInterval(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	// trickle the content so that there are many reads
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			http.ServeContent(w, r, "interval.txt", time.Time{}, bytes.NewReader(content))
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+
+		for i := 0; i < len(content); i += 250 {
+			w.Write(content[i : i+250])
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	url := server.URL + "/interval.txt"
+	interval := 50 * time.Millisecond
+
+	for _, aggregated := range []bool{false, true} {
+
+		var times []time.Time
+		var reads []int64
+
+		f, err := Open(url, &Options{
+			ProgressInterval:  interval,
+			AggregateProgress: aggregated,
+			Progress: func(download int, read, size int64) {
+				times = append(times, time.Now())
+				reads = append(reads, read)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		if len(reads) < 2 || reads[len(reads)-1] != int64(len(content)) {
+			t.Fatalf("%t: Expected a final progress of '%d' got '%v'", aggregated, len(content), reads)
+		}
+
+		// the final notification is always made
+		for i := 1; i < len(times)-1; i++ {
+			if d := times[i].Sub(times[i-1]); d < interval {
+				t.Fatalf("%t: Expected at least '%s' between notifications got '%s'", aggregated, interval, d)
+			}
+		}
+
+		if len(reads) > 10 {
+			t.Fatalf("%t: Expected at most '%d' notifications got '%d'", aggregated, 10, len(reads))
+		}
+	}
+}
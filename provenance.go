@@ -0,0 +1,34 @@
+package download
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Provenance is the expected size and SHA-256 digest of the file as recorded by
+// supply-chain provenance, eg. a subject of an in-toto or SLSA attestation
+type Provenance struct {
+	Size   int64
+	SHA256 string
+}
+
+// verifyProvenance checks the downloaded file's size, then it's digest, against the Options.Provenance
+func (f *File) verifyProvenance() error {
+
+	p := f.options.Provenance
+
+	if size, _ := f.Progress(); size != p.Size {
+		return &ProvenanceMismatch{check: "size", expected: strconv.FormatInt(p.Size, 10), got: strconv.FormatInt(size, 10)}
+	}
+
+	got, err := f.checksum(SHA256)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(got, p.SHA256) {
+		return &ProvenanceMismatch{check: "sha256", expected: p.SHA256, got: got}
+	}
+
+	return nil
+}
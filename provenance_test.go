@@ -0,0 +1,90 @@
+package download
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProvenance(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "provenance.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/provenance.txt"
+
+	tests := []struct {
+		provenance *Provenance
+		check      string
+		expected   string
+	}{
+		{provenance: &Provenance{Size: 1000, SHA256: digest}},
+		{provenance: &Provenance{Size: 1000, SHA256: strings.ToUpper(digest)}},
+		{
+			provenance: &Provenance{Size: 999, SHA256: digest},
+			check:      "size",
+			expected:   "Invalid provenance size, received '1000' expected '999'",
+		},
+		{
+			provenance: &Provenance{Size: 1000, SHA256: "deadbeef"},
+			check:      "sha256",
+			expected:   "Invalid provenance sha256, received '" + digest + "' expected 'deadbeef'",
+		},
+	}
+
+	for _, minSize := range []int64{0, -1} {
+		for i, tt := range tests {
+
+			os.RemoveAll((&File{url: url}).resumeDir())
+
+			f, err := Open(url, &Options{MinSizeForRanges: minSize, Provenance: tt.provenance})
+
+			if tt.check == "" {
+				if err != nil {
+					t.Fatalf("%d: %s", i, err)
+				}
+
+				// the verification doesn't affect reading
+				b, err := ioutil.ReadAll(f)
+				f.Close()
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(b, content) {
+					t.Fatalf("%d: Downloaded content does not match", i)
+				}
+
+				continue
+			}
+
+			mismatch, ok := err.(*ProvenanceMismatch)
+			if !ok {
+				t.Fatalf("%d: Expected error to be of type *ProvenanceMismatch got '%v'", i, err)
+			}
+
+			if mismatch.Check() != tt.check {
+				t.Fatalf("%d: Expected failed check '%s' got '%s'", i, tt.check, mismatch.Check())
+			}
+
+			if err.Error() != tt.expected {
+				t.Fatalf("%d: Expected '%s' got '%s'", i, tt.expected, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// OpenRanges downloads only the given inclusive byte ranges, {start, end}, of the file at url
+// concurrently, eg. the records needed from a large file. ReadAt reads the ranges at their
+// original offsets, reading a gap between them returns a *RangeNotDownloaded error unless
+// Options.ZeroFillGaps is set, while Read reads the ranges one after another.
+// The context provided must be non-nil
+func OpenRanges(ctx context.Context, url string, ranges [][2]int64, options *Options) (*File, error) {
+
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	if err := httpOnly(url, "OpenRanges"); err != nil {
+		return nil, err
+	}
+
+	f, err := newFile(url, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.chunks, err = rangeChunks(ranges); err != nil {
+		return nil, err
+	}
+
+	// the File ends with the last range
+	f.size = f.chunks[len(f.chunks)-1].end + 1
+	f.sparse = true
+
+	return f.opened(ctx, f.within(ctx, f.downloadSparse))
+}
+
+// downloadSparse downloads the chunks of the ranges requested by OpenRanges, they aren't resumed
+// as the ranges may differ between downloads of the url
+func (f *File) downloadSparse(ctx context.Context) error {
+
+	if !f.options.InMemory {
+
+		var err error
+
+		if f.dir, err = ioutil.TempDir(f.options.tempDir(), defaultDir); err != nil {
+			return err
+		}
+	}
+
+	return f.downloadChunks(ctx, false)
+}
+
+// rangeChunks returns the ranges as chunks ordered by their offset, validating that they don't overlap
+func rangeChunks(ranges [][2]int64) ([]chunk, error) {
+
+	if len(ranges) == 0 {
+		return nil, errors.New("Invalid ranges, at least one range is required")
+	}
+
+	chunks := make([]chunk, len(ranges))
+
+	for i, r := range ranges {
+
+		if r[0] < 0 || r[1] < r[0] {
+			return nil, fmt.Errorf("Invalid range '%d-%d'", r[0], r[1])
+		}
+
+		chunks[i] = chunk{start: r[0], end: r[1]}
+	}
+
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].start < chunks[j].start
+	})
+
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].start <= chunks[i-1].end {
+			return nil, fmt.Errorf("Invalid ranges, '%d-%d' overlaps '%d-%d'", chunks[i].start, chunks[i].end, chunks[i-1].start, chunks[i-1].end)
+		}
+	}
+
+	return chunks, nil
+}
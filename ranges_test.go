@@ -0,0 +1,105 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenRanges(t *testing.T) {
+
+	content := make([]byte, 10000)
+	for i := range content {
+		content[i] = byte(i%250) + 1 // no zeros so filled gaps stand out
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "records.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/records.bin"
+	ranges := [][2]int64{{5000, 5999}, {100, 199}, {9000, 9999}}
+
+	// coalescing would lose the offsets of the ranges so is skipped
+	for _, options := range []*Options{{}, {InMemory: true}, {Coalesce: true}} {
+
+		f, err := OpenRanges(context.Background(), url, ranges, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, r := range ranges {
+
+			b := make([]byte, r[1]-r[0]+1)
+
+			if _, err = f.ReadAt(b, r[0]); err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, content[r[0]:r[1]+1]) {
+				t.Fatalf("Range '%d-%d' does not match", r[0], r[1])
+			}
+		}
+
+		b := make([]byte, 100)
+
+		n, err := f.ReadAt(b, 150)
+		if _, ok := err.(*RangeNotDownloaded); !ok {
+			t.Fatalf("Expected error to be of type *RangeNotDownloaded got '%v'", err)
+		}
+
+		if expected := "Range not downloaded, bytes '200-4999'"; err.Error() != expected {
+			t.Fatalf("Expected '%s' got '%s'", expected, err)
+		}
+
+		if n != 50 || !bytes.Equal(b[:n], content[150:200]) {
+			t.Fatalf("Expected the '50' downloaded bytes got '%d'", n)
+		}
+
+		// Read reads the ranges one after another
+		b, err = ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := append(append(append([]byte(nil), content[100:200]...), content[5000:6000]...), content[9000:]...)
+		if !bytes.Equal(b, expected) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		if err = f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := OpenRanges(context.Background(), url, ranges, &Options{ZeroFillGaps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b := make([]byte, 200)
+
+	if _, err = f.ReadAt(b, 150); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b[:50], content[150:200]) || !bytes.Equal(b[50:], make([]byte, 150)) {
+		t.Fatal("Expected the gap to read as zeros")
+	}
+
+	if _, err = f.ReadAt(b, 9900); err != io.EOF {
+		t.Fatalf("Expected '%v' got '%v'", io.EOF, err)
+	}
+
+	_, err = OpenRanges(context.Background(), url, [][2]int64{{0, 99}, {50, 149}}, nil)
+	if expected := "Invalid ranges, '50-149' overlaps '0-99'"; err == nil || err.Error() != expected {
+		t.Fatalf("Expected '%s' got '%v'", expected, err)
+	}
+}
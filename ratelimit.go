@@ -0,0 +1,79 @@
+package download
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter paces the reads of all the chunks of a download to a shared rate
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate int64
+	next time.Time
+}
+
+// wait blocks until the n bytes read have been paid for at the rate,
+// the reads being paced by the time each one takes at the rate
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+
+	if n <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	delay := l.next.Sub(now)
+
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateReader reads from the underlying io.Reader at the rate of the shared rateLimiter
+type rateReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rateLimiter
+}
+
+// Read reads at most a second's worth of bytes, waiting for them to be paid for
+func (r *rateReader) Read(b []byte) (int, error) {
+
+	if int64(len(b)) > r.limiter.rate {
+		b = b[:r.limiter.rate]
+	}
+
+	n, err := r.r.Read(b)
+
+	if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+
+	return n, err
+}
+
+// limitRate returns r limited to the Options.MaxBytesPerSecond shared by the whole download
+func (f *File) limitRate(ctx context.Context, r io.Reader) io.Reader {
+
+	if f.limiter == nil {
+		return r
+	}
+
+	return &rateReader{ctx: ctx, r: r, limiter: f.limiter}
+}
@@ -0,0 +1,62 @@
+package download
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMaxBytesPerSecond(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "limited.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/limited.txt"
+
+	for _, minSize := range []int64{0, -1} {
+
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		// the limit is shared, 4 chunks don't download 4 times faster
+		options := &Options{
+			MinSizeForRanges:  minSize,
+			MaxBytesPerSecond: 20000,
+			Concurrency: func(size int64) int {
+				return 4
+			},
+		}
+
+		start := time.Now()
+
+		f, err := Open(url, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		elapsed := time.Since(start)
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		// 10000 bytes at 20000 bytes per second
+		if elapsed < 450*time.Millisecond || elapsed > 2*time.Second {
+			t.Fatalf("Expected the download to take about 500ms got '%s'", elapsed)
+		}
+	}
+}
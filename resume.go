@@ -0,0 +1,213 @@
+package download
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const resumeMetadataName = "meta.json"
+
+// StateStore persists the resume metadata of interrupted downloads by key, Get
+// returns an error when there is none for the key. It must be safe for concurrent use.
+type StateStore interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+	Delete(key string) error
+}
+
+// fileStateStore is the default StateStore, keeping the metadata in the resume directory
+type fileStateStore struct {
+	dir string
+}
+
+func (s fileStateStore) path(key string) string {
+	return filepath.Join(s.dir, defaultDir+key, resumeMetadataName)
+}
+
+// Get reads the metadata file
+func (s fileStateStore) Get(key string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(key))
+}
+
+// Put writes the metadata file, creating the resume directory if necessary
+func (s fileStateStore) Put(key string, value []byte) error {
+
+	if err := os.MkdirAll(filepath.Dir(s.path(key)), fileMode); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.path(key), value, fileMode)
+}
+
+// Delete removes the metadata file
+func (s fileStateStore) Delete(key string) error {
+
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// stateStore returns the StateStore, or the default one keeping the metadata in the resume directory
+func (o *Options) stateStore() StateStore {
+
+	if o != nil && o.StateStore != nil {
+		return o.StateStore
+	}
+
+	return fileStateStore{dir: o.tempDir()}
+}
+
+// removeResume removes the resume directory and metadata of an interrupted download of the url
+func (f *File) removeResume() error {
+
+	err := os.RemoveAll(f.resumeDir())
+
+	if f.options != nil && f.options.StateStore != nil {
+		if delErr := f.options.StateStore.Delete(f.generateHash()); err == nil {
+			err = delErr
+		}
+	}
+
+	return err
+}
+
+// resumeMetadata is the information about a ranged download stored
+// alongside it's chunks so that it can be resumed reliably
+type resumeMetadata struct {
+	URL          string     `json:"url"`
+	Size         int64      `json:"size"`
+	ETag         string     `json:"etag,omitempty"`
+	LastModified string     `json:"lastModified,omitempty"`
+	Digest       string     `json:"digest,omitempty"`
+	Chunks       [][2]int64 `json:"chunks,omitempty"`
+}
+
+// writeResumeMetadata stores the resume metadata in the StateStore keyed by the url's hash
+func (f *File) writeResumeMetadata() error {
+
+	meta := resumeMetadata{URL: f.url, Size: f.size, ETag: f.etag, LastModified: f.lastModified, Digest: f.digest}
+
+	// only a ranged download's chunks are kept in the resume directory
+	if f.dir == f.resumeDir() {
+		for _, c := range f.chunks {
+			meta.Chunks = append(meta.Chunks, [2]int64{c.start, c.end})
+		}
+	}
+
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return f.options.stateStore().Put(f.generateHash(), b)
+}
+
+// readResumeMetadata returns the metadata stored by a previous, interrupted, download of the url
+func (f *File) readResumeMetadata() (resumeMetadata, bool) {
+
+	var meta resumeMetadata
+
+	b, err := f.options.stateStore().Get(f.generateHash())
+	if err != nil {
+		return meta, false
+	}
+
+	if err = json.Unmarshal(b, &meta); err != nil || meta.URL != f.url {
+		return meta, false
+	}
+
+	return meta, true
+}
+
+// storedSize returns the size stored by a previous, interrupted, ranged download
+func (f *File) storedSize() (int64, bool) {
+
+	meta, ok := f.readResumeMetadata()
+	if !ok || meta.Size <= 0 {
+		return 0, false
+	}
+
+	return meta.Size, true
+}
+
+// storedChunks returns the chunks of a previous, interrupted, ranged download, none when it's
+// metadata predates them, or an error when they don't partition the file of the current size
+func (f *File) storedChunks() ([]chunk, error) {
+
+	meta, ok := f.readResumeMetadata()
+	if !ok || len(meta.Chunks) == 0 {
+		return nil, nil
+	}
+
+	chunks, err := rangeChunks(meta.Chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, c := range chunks {
+
+		start := int64(0)
+		if i > 0 {
+			start = chunks[i-1].end + 1
+		}
+
+		if c.start != start {
+			return nil, fmt.Errorf("Invalid stored chunks, gap before '%d-%d'", c.start, c.end)
+		}
+	}
+
+	if end := chunks[len(chunks)-1].end; end != f.size-1 {
+		return nil, fmt.Errorf("Invalid stored chunks, ending at '%d' of size '%d'", end, f.size)
+	}
+
+	return chunks, nil
+}
+
+// resumeChanged returns if the remote file changed since the interrupted download
+// according to it's ETag or Last-Modified, compared only when both are known
+func (f *File) resumeChanged() bool {
+
+	meta, ok := f.readResumeMetadata()
+	if !ok {
+		return false
+	}
+
+	return meta.ETag != "" && f.etag != "" && meta.ETag != f.etag ||
+		meta.LastModified != "" && f.lastModified != "" && meta.LastModified != f.lastModified
+}
+
+// resumedSize returns the number of bytes of the interrupted ranged download's chunks
+func (f *File) resumedSize() int64 {
+
+	infos, err := ioutil.ReadDir(f.resumeDir())
+	if err != nil {
+		return 0
+	}
+
+	var size int64
+
+	for _, fi := range infos {
+		if _, err := strconv.Atoi(fi.Name()); err == nil {
+			size += fi.Size()
+		}
+	}
+
+	return size
+}
+
+// worthResuming returns if enough of the interrupted download, done bytes,
+// was downloaded to resume it according to Options.MinResumePercent
+func (f *File) worthResuming(done int64) bool {
+
+	if f.options.MinResumePercent <= 0 || f.size <= 0 {
+		return true
+	}
+
+	return float64(done)*100 >= f.options.MinResumePercent*float64(f.size)
+}
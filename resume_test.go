@@ -0,0 +1,486 @@
+package download
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResumeStoredSize(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var resuming int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if atomic.LoadInt32(&resuming) == 0 {
+
+			// interrupt the first download by failing the last chunk
+			if r.Header.Get("Range") == "bytes=500-999" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			http.ServeContent(w, r, "stored.txt", time.Time{}, bytes.NewReader(content))
+			return
+		}
+
+		// when resuming the HEAD omits the length and the probe fails
+		switch {
+		case r.Method == http.MethodHead:
+			w.Header().Set("Accept-Ranges", "bytes")
+		case r.Header.Get("Range") == "bytes=0-0":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.ServeContent(w, r, "stored.txt", time.Time{}, bytes.NewReader(content))
+		}
+	}))
+	defer server.Close()
+
+	url := server.URL + "/stored.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+	defer os.RemoveAll((&File{url: url}).resumeDir())
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		KeepOnFailure:    true,
+		Concurrency: func(size int64) int {
+			return 2
+		},
+	}
+
+	if _, err := Open(url, options); err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+
+	if size, ok := (&File{url: url}).storedSize(); !ok || size != int64(len(content)) {
+		t.Fatalf("Expected stored size '%d' got '%d'", len(content), size)
+	}
+
+	atomic.StoreInt32(&resuming, 1)
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+}
+
+func TestMinResumePercent(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var ranges []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if rng := r.Header.Get("Range"); rng != "" {
+			m.Lock()
+			ranges = append(ranges, rng)
+			m.Unlock()
+		}
+
+		http.ServeContent(w, r, "percent.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/percent.txt"
+	dir := (&File{url: url}).resumeDir()
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		partials map[string][]byte
+		expected []string
+	}{
+		{
+			name:     "1%",
+			partials: map[string][]byte{"0": content[:10]},
+			expected: []string{"bytes=0-249", "bytes=250-499", "bytes=500-749", "bytes=750-999"},
+		},
+		{
+			name:     "50%",
+			partials: map[string][]byte{"0": content[:250], "1": content[250:500]},
+			expected: []string{"bytes=500-749", "bytes=750-999"},
+		},
+	}
+
+	for _, tt := range tests {
+
+		os.RemoveAll(dir)
+
+		if err := os.Mkdir(dir, fileMode); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, b := range tt.partials {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), b, fileMode); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		m.Lock()
+		ranges = nil
+		m.Unlock()
+
+		f, err := Open(url, &Options{
+			MinSizeForRanges: -1,
+			MinResumePercent: 10,
+			Concurrency: func(size int64) int {
+				return 4
+			},
+		})
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%s: Downloaded content does not match", tt.name)
+		}
+
+		m.Lock()
+		sort.Strings(ranges)
+		if !reflect.DeepEqual(ranges, tt.expected) {
+			t.Fatalf("%s: Expected ranges '%v' got '%v'", tt.name, tt.expected, ranges)
+		}
+		m.Unlock()
+	}
+}
+
+func TestResumeChanged(t *testing.T) {
+
+	v1 := bytes.Repeat([]byte("0123456789"), 100)
+	v2 := bytes.Repeat([]byte("9876543210"), 100)
+
+	tests := []struct {
+		name     string
+		validate func(w http.ResponseWriter, version int)
+	}{
+		{
+			name: "etag",
+			validate: func(w http.ResponseWriter, version int) {
+				w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, version))
+			},
+		},
+		{
+			name: "last-modified",
+			validate: func(w http.ResponseWriter, version int) {
+				w.Header().Set("Last-Modified", time.Date(2017, 1, version, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+
+		var version int32 = 1
+		var m sync.Mutex
+		var ranges []string
+
+		validate := tt.validate
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			v := atomic.LoadInt32(&version)
+
+			content := v1
+			if v == 2 {
+				content = v2
+			}
+
+			if rng := r.Header.Get("Range"); rng != "" {
+				m.Lock()
+				ranges = append(ranges, rng)
+				m.Unlock()
+
+				// interrupt the first download by failing the last chunk
+				if v == 1 && rng == "bytes=500-999" {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+			}
+
+			validate(w, int(v))
+			http.ServeContent(w, r, "changed.txt", time.Time{}, bytes.NewReader(content))
+		}))
+
+		url := server.URL + "/changed.txt"
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		options := &Options{
+			MinSizeForRanges: -1,
+			Concurrency: func(size int64) int {
+				return 2
+			},
+		}
+
+		if _, err := Open(url, options); err == nil {
+			server.Close()
+			t.Fatalf("%s: Expected error got <nil>", tt.name)
+		}
+
+		// the file changes on the server before resuming
+		atomic.StoreInt32(&version, 2)
+
+		m.Lock()
+		ranges = nil
+		m.Unlock()
+
+		f, err := Open(url, options)
+		if err != nil {
+			server.Close()
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+		server.Close()
+
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		if !bytes.Equal(b, v2) {
+			t.Fatalf("%s: Expected the stale chunk to be discarded got '%s'", tt.name, b[:10])
+		}
+
+		sort.Strings(ranges)
+
+		if expected := []string{"bytes=0-499", "bytes=500-999"}; !reflect.DeepEqual(ranges, expected) {
+			t.Fatalf("%s: Expected ranges '%v' got '%v'", tt.name, expected, ranges)
+		}
+	}
+}
+
+func TestResumeChunkLayout(t *testing.T) {
+
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+
+	var resuming int32
+	var m sync.Mutex
+	var ranges []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if atomic.LoadInt32(&resuming) == 0 {
+
+			// interrupt the first download by failing the last chunk
+			if r.Header.Get("Range") == "bytes=750-999" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		} else if r.Method == http.MethodGet {
+			m.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			m.Unlock()
+		}
+
+		http.ServeContent(w, r, "layout.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/layout.bin"
+	os.RemoveAll((&File{url: url}).resumeDir())
+	defer os.RemoveAll((&File{url: url}).resumeDir())
+
+	concurrency := 4
+
+	options := &Options{
+		MinSizeForRanges: -1,
+		KeepOnFailure:    true,
+		DrainOnError:     true,
+		Concurrency: func(size int64) int {
+			return concurrency
+		},
+	}
+
+	if _, err := Open(url, options); err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+
+	// resumed with a different concurrency
+	atomic.StoreInt32(&resuming, 1)
+	concurrency = 2
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	if expected := []string{"bytes=750-999"}; !reflect.DeepEqual(ranges, expected) {
+		t.Fatalf("Expected ranges '%v' got '%v'", expected, ranges)
+	}
+}
+
+// memStateStore is an in memory StateStore
+type memStateStore struct {
+	m      sync.Mutex
+	values map[string][]byte
+}
+
+func (s *memStateStore) Get(key string) ([]byte, error) {
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	b, ok := s.values[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return b, nil
+}
+
+func (s *memStateStore) Put(key string, value []byte) error {
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.values[key] = value
+
+	return nil
+}
+
+func (s *memStateStore) Delete(key string) error {
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.values, key)
+
+	return nil
+}
+
+func TestStateStore(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var resuming int32
+	var m sync.Mutex
+	var ranges []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if atomic.LoadInt32(&resuming) == 0 {
+
+			// interrupt the first download by failing the last chunk
+			if r.Header.Get("Range") == "bytes=500-999" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			http.ServeContent(w, r, "state.txt", time.Time{}, bytes.NewReader(content))
+			return
+		}
+
+		m.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		m.Unlock()
+
+		http.ServeContent(w, r, "state.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/state.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+	defer os.RemoveAll((&File{url: url}).resumeDir())
+
+	store := &memStateStore{values: make(map[string][]byte)}
+
+	// the first chunk completes rather than being cancelled when the last fails
+	options := &Options{
+		MinSizeForRanges: -1,
+		DrainOnError:     true,
+		KeepOnFailure:    true,
+		StateStore:       store,
+		Concurrency: func(size int64) int {
+			return 2
+		},
+	}
+
+	if _, err := Open(url, options); err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+
+	f := &File{url: url, options: options}
+
+	if _, err := store.Get(f.generateHash()); err != nil {
+		t.Fatalf("Expected the metadata to be stored got '%v'", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(f.resumeDir(), resumeMetadataName)); !os.IsNotExist(err) {
+		t.Fatalf("Expected no metadata file got '%v'", err)
+	}
+
+	atomic.StoreInt32(&resuming, 1)
+
+	f, err := Open(url, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(f)
+	f.Close()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Downloaded content does not match")
+	}
+
+	// the completed first chunk was kept from the stored metadata
+	m.Lock()
+	got := ranges
+	m.Unlock()
+
+	if expected := []string{"", "bytes=500-999"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected ranges '%v' got '%v'", expected, got)
+	}
+
+	if _, err = store.Get(f.generateHash()); err == nil {
+		t.Fatal("Expected the metadata to be deleted once downloaded")
+	}
+}
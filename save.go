@@ -0,0 +1,169 @@
+package download
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	metadataExt = ".meta.json"
+	checksumExt = ".sha256"
+)
+
+// metadata is the information about a download written by SaveWithMetadata
+type metadata struct {
+	URL         string    `json:"url"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type,omitempty"`
+	SHA256      string    `json:"sha256"`
+	Downloaded  time.Time `json:"downloaded"`
+}
+
+// Save writes the whole downloaded file to path, creating any missing directories, or when path
+// is an existing directory to the Stat name within it. The saved file's modification time is set
+// to the File's. With Options.WriteChecksumFile a path + ".sha256" checksum file is written too.
+func (f *File) Save(path string) error {
+
+	_, _, err := f.save(path)
+	return err
+}
+
+// SaveGzip writes the whole downloaded file gzip compressed to path, typically name + ".gz",
+// in a single pass using the Options.GzipLevel. The gzip header records the Stat name and
+// modification time.
+func (f *File) SaveGzip(path string) error {
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	level := f.options.GzipLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	fh, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	gz, err := gzip.NewWriterLevel(fh, level)
+	if err != nil {
+		return err
+	}
+
+	gz.Name = fi.Name()
+	gz.ModTime = fi.ModTime()
+
+	if _, err = f.copy(gz, f); err != nil {
+		return err
+	}
+
+	if err = gz.Close(); err != nil {
+		return err
+	}
+
+	return fh.Close()
+}
+
+// SaveWithMetadata saves the downloaded file to path, as Save does, along with a path + ".meta.json"
+// file containing the source url, size, content type, sha256 checksum and time of the download.
+func (f *File) SaveWithMetadata(path string) error {
+
+	path, sum, err := f.save(path)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(metadata{
+		URL:         f.url,
+		Size:        fi.Size(),
+		ContentType: f.mimeType,
+		SHA256:      sum,
+		Downloaded:  time.Now(), // the File's ModTime is the remote Last-Modified, if known
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path+metadataExt, b, 0666)
+}
+
+// save implements Save returning the path saved to and the file's hex encoded sha256 checksum
+func (f *File) save(path string) (string, string, error) {
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", "", err
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, fi.Name())
+	} else if err = os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return "", "", err
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return "", "", err
+	}
+
+	fh, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return "", "", err
+	}
+	defer fh.Close()
+
+	h := sha256.New()
+
+	if _, err = f.copy(io.MultiWriter(fh, h), f); err != nil {
+		return "", "", err
+	}
+
+	if err = fh.Close(); err != nil {
+		return "", "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	if f.options.WriteChecksumFile {
+
+		// the sha256sum format
+		line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+
+		if err = ioutil.WriteFile(path+checksumExt, []byte(line), 0666); err != nil {
+			return "", "", err
+		}
+	}
+
+	return path, sum, os.Chtimes(path, fi.ModTime(), fi.ModTime())
+}
+
+// WriteToAll writes the whole downloaded file to all of the writers in a single pass,
+// eg. to a cache and a client, returning the first write error.
+func (f *File) WriteToAll(writers ...io.Writer) (int64, error) {
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return io.Copy(io.MultiWriter(writers...), f)
+}
@@ -0,0 +1,364 @@
+package download
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveWithMetadata(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/metadata.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.ServeContent(w, r, "metadata.txt", time.Time{}, bytes.NewReader(content))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	url := server.URL + "/testdata/metadata.txt"
+
+	dir, err := ioutil.TempDir("", "go-download-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := Open(url, &Options{MinSizeForRanges: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// partially read to ensure the whole file is still saved
+	f.Read(make([]byte, 10))
+
+	path := filepath.Join(dir, "metadata.txt")
+
+	if err = f.SaveWithMetadata(path); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, content) {
+		t.Fatal("Saved content does not match")
+	}
+
+	b, err = ioutil.ReadFile(path + ".meta.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var meta metadata
+
+	if err = json.Unmarshal(b, &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(content)
+
+	expected := metadata{
+		URL:         url,
+		Size:        int64(len(content)),
+		ContentType: "text/plain; charset=utf-8",
+		SHA256:      hex.EncodeToString(sum[:]),
+	}
+
+	if meta.Downloaded.IsZero() {
+		t.Fatal("Expected download time to be populated")
+	}
+
+	meta.Downloaded = time.Time{}
+
+	if meta != expected {
+		t.Fatalf("Expected '%+v' got '%+v'", expected, meta)
+	}
+
+	// saved as Save would, into the directory with the checksum file
+	f.options.WriteChecksumFile = true
+
+	nested := filepath.Join(dir, "nested")
+	if err = os.Mkdir(nested, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = f.SaveWithMetadata(nested); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"metadata.txt", "metadata.txt.meta.json", "metadata.txt.sha256"} {
+		if _, err = os.Stat(filepath.Join(nested, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWriteToAll(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "all.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/all.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	f, err := Open(url, &Options{MinSizeForRanges: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// partially read to ensure the whole file is written regardless
+	if _, err = f.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+
+	var cache, client bytes.Buffer
+
+	n, err := f.WriteToAll(&cache, &client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != int64(len(content)) {
+		t.Fatalf("Expected '%d' bytes written got '%d'", len(content), n)
+	}
+
+	if !bytes.Equal(cache.Bytes(), content) || !bytes.Equal(client.Bytes(), content) {
+		t.Fatal("Written content does not match")
+	}
+
+	expected := errors.New("write failed")
+
+	if _, err = f.WriteToAll(&cache, failingWriter{err: expected}); err != expected {
+		t.Fatalf("Expected '%s' got '%v'", expected, err)
+	}
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(b []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestSave(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "save.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "go-download-save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: dir, expected: filepath.Join(dir, "save.txt")},
+		{path: filepath.Join(dir, "nested", "dirs", "renamed.txt"), expected: filepath.Join(dir, "nested", "dirs", "renamed.txt")},
+	}
+
+	for _, minSize := range []int64{0, -1} {
+		for _, tt := range tests {
+
+			f, err := Open(server.URL+"/testdata/save.txt", &Options{MinSizeForRanges: minSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fi, err := f.Stat()
+			if err != nil {
+				f.Close()
+				t.Fatal(err)
+			}
+
+			// the whole file is saved regardless of the read position
+			f.Read(make([]byte, 10))
+
+			err = f.Save(tt.path)
+			f.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := ioutil.ReadFile(tt.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, content) {
+				t.Fatal("Saved content does not match")
+			}
+
+			saved, err := os.Stat(tt.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !saved.ModTime().Equal(fi.ModTime()) {
+				t.Fatalf("Expected modification time '%s' got '%s'", fi.ModTime(), saved.ModTime())
+			}
+		}
+	}
+}
+
+func TestWriteChecksumFile(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "checksum.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "go-download-checksum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sum := sha256.Sum256(content)
+	expected := hex.EncodeToString(sum[:]) + "  checksum.txt\n"
+
+	for _, write := range []bool{false, true} {
+
+		f, err := Open(server.URL+"/testdata/checksum.txt", &Options{WriteChecksumFile: write})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = f.Save(dir)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(dir, "checksum.txt")
+
+		b, err := ioutil.ReadFile(path + ".sha256")
+
+		if !write {
+			if !os.IsNotExist(err) {
+				t.Fatalf("Expected no checksum file got '%v'", err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != expected {
+			t.Fatalf("Expected '%s' got '%s'", expected, b)
+		}
+
+		if b, err = ioutil.ReadFile(path); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Saved content does not match")
+		}
+	}
+}
+
+func TestSaveGzip(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "archive.txt", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "go-download-gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, minSize := range []int64{0, -1} {
+		for _, level := range []int{0, gzip.BestSpeed, gzip.BestCompression} {
+
+			f, err := Open(server.URL+"/testdata/archive.txt", &Options{MinSizeForRanges: minSize, GzipLevel: level})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(dir, "archive.txt.gz")
+
+			err = f.SaveGzip(path)
+			f.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fh, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gz, err := gzip.NewReader(fh)
+			if err != nil {
+				fh.Close()
+				t.Fatal(err)
+			}
+
+			b, err := ioutil.ReadAll(gz)
+			fh.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, content) {
+				t.Fatalf("Decompressed content does not match for level '%d'", level)
+			}
+
+			if gz.Name != "archive.txt" || !gz.ModTime.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) {
+				t.Fatalf("Expected gzip header 'archive.txt' '2020-01-02 03:04:05' got '%s' '%s'", gz.Name, gz.ModTime)
+			}
+		}
+	}
+
+	f, err := Open(server.URL+"/testdata/archive.txt", &Options{GzipLevel: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err = f.SaveGzip(filepath.Join(dir, "invalid.txt.gz")); err == nil {
+		t.Fatal("Expected error got <nil>")
+	}
+}
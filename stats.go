@@ -0,0 +1,40 @@
+package download
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DownloadStats are the metrics of a download, eg. to tune the Options.Concurrency
+type DownloadStats struct {
+
+	// Duration is how long the download took, including the verification
+	Duration time.Duration
+
+	// Ranged is whether the file was downloaded in ranged chunks rather than a single stream
+	Ranged bool
+
+	// Concurrency is the number of chunks downloaded at once, 1 for a single stream
+	Concurrency int
+
+	// ChunkBytes is the number of bytes fetched by each chunk, excluding those resumed
+	ChunkBytes []int64
+
+	// ResumedBytes is the number of bytes resumed from an interrupted download
+	ResumedBytes int64
+
+	// Retries is the number of times the chunks were retried
+	Retries int
+}
+
+// Stats returns the metrics collected while downloading the File. It is intended
+// to be called once the File is opened, it doesn't reflect a download in progress.
+func (f *File) Stats() DownloadStats {
+
+	stats := f.stats
+	stats.ChunkBytes = append([]int64(nil), f.stats.ChunkBytes...)
+	stats.ResumedBytes = atomic.LoadInt64(&f.resumed)
+	stats.Retries = int(atomic.LoadInt64(&f.retries))
+
+	return stats
+}
@@ -0,0 +1,92 @@
+package download
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	var failed int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// the last chunk fails once
+		if r.Header.Get("Range") == "bytes=7500-9999" && atomic.CompareAndSwapInt32(&failed, 0, 1) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		http.ServeContent(w, r, "stats.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/testdata/stats.txt"
+	dir := (&File{url: url, options: new(Options)}).resumeDir()
+
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	f, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		MaxRetries:       1,
+		MaxParallel:      2,
+		Concurrency: func(size int64) int {
+			return 4
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	stats := f.Stats()
+
+	if !stats.Ranged || stats.Concurrency != 2 || stats.Retries != 1 || stats.ResumedBytes != 0 || stats.Duration <= 0 {
+		t.Fatalf("Unexpected ranged stats '%+v'", stats)
+	}
+
+	if len(stats.ChunkBytes) != 4 {
+		t.Fatalf("Expected '4' chunks got '%d'", len(stats.ChunkBytes))
+	}
+
+	for i, n := range stats.ChunkBytes {
+		if n != 2500 {
+			t.Fatalf("Expected chunk '%d' to fetch '2500' bytes got '%d'", i, n)
+		}
+	}
+
+	// a single stream resuming an interrupted download
+	if err = os.MkdirAll(dir, fileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, partialName), content[:4000], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = Open(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	stats = f.Stats()
+
+	if stats.Ranged || stats.Concurrency != 1 || stats.Retries != 0 || stats.ResumedBytes != 4000 {
+		t.Fatalf("Unexpected single stream stats '%+v'", stats)
+	}
+
+	if len(stats.ChunkBytes) != 1 || stats.ChunkBytes[0] != 6000 {
+		t.Fatalf("Expected a single stream of '6000' bytes got '%v'", stats.ChunkBytes)
+	}
+}
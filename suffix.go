@@ -0,0 +1,91 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// downloadSuffix downloads the last Options.Suffix bytes of the file using a suffix
+// range request, the Content-Range determining their offset and the total size.
+func (f *File) downloadSuffix(ctx context.Context) error {
+
+	req, err := f.newRequest(ctx, http.MethodGet, f.url)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Range", fmt.Sprintf("bytes=-%d", f.options.Suffix))
+
+	if f.options.Request != nil {
+		f.options.Request(req)
+	}
+
+	resp, err := f.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	f.redirected(resp)
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return &InvalidResponseCode{got: resp.StatusCode, expected: http.StatusPartialContent}
+	}
+
+	start, end, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return err
+	}
+
+	f.mimeType = resp.Header.Get("Content-Type")
+	f.lastModified = resp.Header.Get("Last-Modified")
+
+	f.dir, err = ioutil.TempDir(f.options.tempDir(), defaultDir)
+	if err != nil {
+		return err
+	}
+
+	fh, err := f.createTempFile()
+	if err != nil {
+		return err
+	}
+
+	f.readers = []chunkReader{fh}
+
+	read := f.limitRate(ctx, resp.Body)
+
+	if f.options.Proxy != nil {
+		read = f.options.Proxy(f.baseName, 0, (end-start)+1, read)
+	}
+
+	if _, err = f.copy(fh, read); err != nil {
+		return err
+	}
+
+	if _, err = fh.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	f.offset = start
+	f.total = total
+	f.size = (end - start) + 1
+	f.chunks = []chunk{{start: 0, end: f.size - 1}}
+	f.Reader = fh
+	f.modTime = f.remoteModTime()
+
+	return nil
+}
+
+// ContentRange returns the inclusive byte range of the remote file the File contains
+// and the remote file's total size, -1 if unknown. Unless downloaded using
+// Options.Suffix the File contains the whole remote file.
+func (f *File) ContentRange() (start, end, total int64) {
+
+	if f.offset == 0 && f.total == 0 {
+		return 0, f.size - 1, f.size
+	}
+
+	return f.offset, f.offset + f.size - 1, f.total
+}
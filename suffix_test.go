@@ -0,0 +1,87 @@
+package download
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSuffix(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var heads int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			atomic.AddInt32(&heads, 1)
+		}
+
+		http.ServeContent(w, r, "trailer.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/trailer.txt"
+
+	tests := []struct {
+		suffix int64
+		start  int64
+	}{
+		{suffix: 100, start: 900},
+		{suffix: 1, start: 999},
+		{suffix: 5000, start: 0},
+	}
+
+	for _, tt := range tests {
+
+		f, err := Open(url, &Options{Suffix: tt.suffix})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content[tt.start:]) {
+			t.Fatalf("Expected the last '%d' bytes got '%s'", tt.suffix, b)
+		}
+
+		start, end, total := f.ContentRange()
+		if start != tt.start || end != 999 || total != 1000 {
+			t.Fatalf("Expected range '%d-%d/%d' got '%d-%d/%d'", tt.start, 999, 1000, start, end, total)
+		}
+
+		fi, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fi.Size() != 1000-tt.start {
+			t.Fatalf("Expected size '%d' got '%d'", 1000-tt.start, fi.Size())
+		}
+
+		f.Close()
+	}
+
+	if n := atomic.LoadInt32(&heads); n != 0 {
+		t.Fatalf("Expected no HEAD requests got '%d'", n)
+	}
+
+	// a whole file download contains the whole range
+	f, err := Open(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if start, end, total := f.ContentRange(); start != 0 || end != 999 || total != 1000 {
+		t.Fatalf("Expected range '0-999/1000' got '%d-%d/%d'", start, end, total)
+	}
+}
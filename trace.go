@@ -0,0 +1,29 @@
+package download
+
+import "context"
+
+var _ Tracer = noopTracer{}
+
+// Tracer starts spans, eg. OpenTelemetry ones, around the HEAD request and
+// each chunk download; the returned func ends the span.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, func())
+}
+
+// noopTracer is the Tracer used when none is set
+type noopTracer struct{}
+
+// StartSpan returns the context as is and a func that does nothing
+func (noopTracer) StartSpan(ctx context.Context, name string) (context.Context, func()) {
+	return ctx, func() {}
+}
+
+// tracer returns the Tracer to use for the download
+func (f *File) tracer() Tracer {
+
+	if f.options.Tracer != nil {
+		return f.options.Tracer
+	}
+
+	return noopTracer{}
+}
@@ -0,0 +1,80 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingTracer struct {
+	m     sync.Mutex
+	spans []string
+	open  int
+}
+
+func (r *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, func()) {
+
+	r.m.Lock()
+	r.open++
+	r.m.Unlock()
+
+	return ctx, func() {
+		r.m.Lock()
+		r.spans = append(r.spans, name)
+		r.open--
+		r.m.Unlock()
+	}
+}
+
+func TestTracer(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "trace.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/trace.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	tracer := new(recordingTracer)
+
+	f, err := Open(url, &Options{
+		MinSizeForRanges: -1,
+		Concurrency: func(size int64) int {
+			return 3
+		},
+		Tracer: tracer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tracer.m.Lock()
+	defer tracer.m.Unlock()
+
+	if tracer.open != 0 {
+		t.Fatalf("Expected all spans to be ended, '%d' still open", tracer.open)
+	}
+
+	sort.Strings(tracer.spans)
+
+	expected := []string{"chunk 0", "chunk 1", "chunk 2", "head"}
+	if len(tracer.spans) != len(expected) {
+		t.Fatalf("Expected spans '%v' got '%v'", expected, tracer.spans)
+	}
+
+	for i := range expected {
+		if tracer.spans[i] != expected[i] {
+			t.Fatalf("Expected spans '%v' got '%v'", expected, tracer.spans)
+		}
+	}
+}
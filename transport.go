@@ -0,0 +1,128 @@
+package download
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	transportsMu sync.Mutex
+	transports   = make(map[transportConfig]http.RoundTripper)
+)
+
+// transportConfig contains the Options that affect the http.Transport, downloads
+// with the same config share a transport and so can reuse it's connections
+type transportConfig struct {
+	idleConnTimeout   time.Duration
+	disableKeepAlives bool
+	connMaxLifetime   time.Duration
+	localAddr         string
+}
+
+// localAddr returns the Options.LocalAddr as a TCP address, nil when unset or invalid
+func (o *Options) localAddr() *net.TCPAddr {
+
+	if o.LocalAddr == nil {
+		return nil
+	}
+
+	addr, err := net.ResolveTCPAddr("tcp", o.LocalAddr.String())
+	if err != nil {
+		return nil
+	}
+
+	return addr
+}
+
+func (f *File) transportConfig() transportConfig {
+
+	config := transportConfig{
+		idleConnTimeout:   f.options.IdleConnTimeout,
+		disableKeepAlives: f.options.DisableKeepAlives,
+		connMaxLifetime:   f.options.ConnMaxLifetime,
+	}
+
+	// the address, not the net.Addr which may not be comparable, keys the transport
+	if addr := f.options.localAddr(); addr != nil {
+		config.localAddr = addr.String()
+	}
+
+	return config
+}
+
+// transport returns the shared http.RoundTripper for the config, creating it if necessary
+func (c transportConfig) transport() http.RoundTripper {
+
+	if c == (transportConfig{}) {
+		return http.DefaultTransport
+	}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	if t, ok := transports[c]; ok {
+		return t
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if c.localAddr != "" {
+
+		// already validated by Options.localAddr
+		if addr, err := net.ResolveTCPAddr("tcp", c.localAddr); err == nil {
+			dialer.LocalAddr = addr
+		}
+	}
+
+	// same as http.DefaultTransport aside from the configured values
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		DisableKeepAlives:     c.disableKeepAlives,
+	}
+
+	if c.idleConnTimeout > 0 {
+		t.IdleConnTimeout = c.idleConnTimeout
+	}
+
+	var rt http.RoundTripper = t
+
+	if c.connMaxLifetime > 0 {
+		rt = &lifetimeTransport{Transport: t, lifetime: c.connMaxLifetime, rotated: time.Now()}
+	}
+
+	transports[c] = rt
+
+	return rt
+}
+
+// lifetimeTransport rotates the connections of the http.Transport by closing the idle
+// ones every lifetime, connections in use are closed once they next become idle.
+type lifetimeTransport struct {
+	*http.Transport
+	lifetime time.Duration
+	mu       sync.Mutex
+	rotated  time.Time
+}
+
+// RoundTrip closes the idle connections, if due, before executing the http request
+func (t *lifetimeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	t.mu.Lock()
+	if time.Since(t.rotated) >= t.lifetime {
+		t.Transport.CloseIdleConnections()
+		t.rotated = time.Now()
+	}
+	t.mu.Unlock()
+
+	return t.Transport.RoundTrip(req)
+}
@@ -0,0 +1,174 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnMaxLifetime(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var conns int
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		// a long download
+		if r.Method == http.MethodGet {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		http.ServeContent(w, r, "lifetime.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	server.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			m.Lock()
+			conns++
+			m.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		lifetime time.Duration
+		expected int
+	}{
+		{lifetime: 0, expected: 1},
+		{lifetime: 50 * time.Millisecond, expected: 2},
+	}
+
+	for i, tt := range tests {
+
+		url := server.URL + "/lifetime.txt"
+		os.RemoveAll((&File{url: url}).resumeDir())
+
+		m.Lock()
+		conns = 0
+		m.Unlock()
+
+		// a distinct idle timeout so the connections aren't shared with the other tests
+		f, err := Open(url, &Options{ConnMaxLifetime: tt.lifetime, IdleConnTimeout: 42 * time.Second})
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatalf("%d: Downloaded content does not match", i)
+		}
+
+		// the connection used for the HEAD and the download is rotated
+		// before being reused as it exceeded the lifetime in the meantime
+		if _, err = f.RefreshStat(context.Background()); err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+
+		f.Close()
+
+		m.Lock()
+		if conns != tt.expected {
+			t.Fatalf("%d: Expected '%d' connections got '%d'", i, tt.expected, conns)
+		}
+		m.Unlock()
+	}
+}
+
+func TestLocalAddr(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	var m sync.Mutex
+	var remotes []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+
+		m.Lock()
+		remotes = append(remotes, host)
+		m.Unlock()
+
+		http.ServeContent(w, r, "localaddr.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	// the whole of 127.0.0.0/8 is routed to the loopback interface
+	local := &net.TCPAddr{IP: net.ParseIP("127.0.0.2")}
+
+	probe, err := (&net.Dialer{LocalAddr: local}).Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Skipf("binding to '%s' not supported: %s", local, err)
+	}
+	probe.Close()
+
+	url := server.URL + "/localaddr.txt"
+	os.RemoveAll((&File{url: url}).resumeDir())
+
+	for _, minSize := range []int64{0, -1} {
+
+		m.Lock()
+		remotes = nil
+		m.Unlock()
+
+		f, err := Open(url, &Options{LocalAddr: local, MinSizeForRanges: minSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		m.Lock()
+		for _, remote := range remotes {
+			if remote != local.IP.String() {
+				t.Fatalf("Expected all requests from '%s' got '%v'", local.IP, remotes)
+			}
+		}
+		m.Unlock()
+	}
+}
+
+func TestDefaultTransport(t *testing.T) {
+
+	// HEAD, single stream and ranged requests all share the http.DefaultTransport and so
+	// any customisation of it, eg. a proxy or RootCAs
+	f, err := newFile("http://localhost/default.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client := f.client(); client.Transport != http.DefaultTransport {
+		t.Fatalf("Expected the http.DefaultTransport got '%v'", client.Transport)
+	}
+
+	f, err = newFile("http://localhost/default.txt", &Options{DisableKeepAlives: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client := f.client(); client.Transport == http.DefaultTransport {
+		t.Fatal("Expected a configured transport got the http.DefaultTransport")
+	}
+}
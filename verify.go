@@ -0,0 +1,151 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// verify runs the configured verifications against the downloaded file
+func (f *File) verify(ctx context.Context) error {
+
+	if len(f.options.PieceHashes) > 0 {
+		if err := f.verifyPieces(ctx); err != nil {
+			return err
+		}
+
+		// reading the pieces moves the read position when single stream
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	if len(f.options.MagicBytes) > 0 {
+		if err := f.verifyMagicBytes(); err != nil {
+			return err
+		}
+	}
+
+	if f.options.Checksum != "" {
+		if err := f.verifyChecksum(); err != nil {
+			return err
+		}
+	}
+
+	if f.digest != "" {
+		if err := f.verifyDigest(); err != nil {
+			return err
+		}
+	}
+
+	if f.options.Provenance != nil {
+		if err := f.verifyProvenance(); err != nil {
+			return err
+		}
+	}
+
+	if f.options.ValidateJSON {
+		if err := f.verifyJSON(); err != nil {
+			return err
+		}
+	}
+
+	if f.options.Verify != nil {
+		if err := f.options.Verify(io.NewSectionReader(f, 0, f.size)); err != nil {
+			return err
+		}
+
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	if f.options.Accept != nil {
+		if err := f.accept(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// accept asks the Options.Accept function whether to keep the download
+func (f *File) accept() error {
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	ok, err := f.options.Accept(fi, io.NewSectionReader(f, 0, f.size))
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return &Rejected{url: f.url}
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+func (f *File) verifyMagicBytes() error {
+
+	magic := f.options.MagicBytes
+	b := make([]byte, len(magic))
+
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	if !bytes.Equal(b[:n], magic) {
+		return &MagicMismatch{expected: magic, got: b[:n]}
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+// verifyJSON checks the downloaded file is a single well-formed JSON value
+func (f *File) verifyJSON() error {
+
+	dec := json.NewDecoder(io.NewSectionReader(f, 0, f.size))
+
+	var depth, values int
+
+	for {
+
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return &InvalidContent{format: "JSON", reason: err.Error()}
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+
+		if depth == 0 {
+			values++
+		}
+	}
+
+	switch {
+	case depth > 0:
+		return &InvalidContent{format: "JSON", reason: "unexpected end of content"}
+	case values != 1:
+		return &InvalidContent{format: "JSON", reason: fmt.Sprintf("expected a single value got '%d'", values)}
+	}
+
+	return nil
+}
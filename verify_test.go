@@ -0,0 +1,315 @@
+package download
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMagicBytes(t *testing.T) {
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+	html := []byte("<html><body>Not Found</body></html>")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/testdata/image.png", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "image.png", time.Time{}, bytes.NewReader(png))
+	})
+	mux.HandleFunc("/testdata/soft-404.png", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "soft-404.png", time.Time{}, bytes.NewReader(html))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, minSize := range []int64{0, -1} {
+
+		options := &Options{
+			MagicBytes:       []byte("\x89PNG\r\n\x1a\n"),
+			MinSizeForRanges: minSize,
+		}
+
+		f, err := Open(server.URL+"/testdata/image.png", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, png) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		_, err = Open(server.URL+"/testdata/soft-404.png", options)
+		if _, ok := err.(*MagicMismatch); !ok {
+			t.Fatalf("Expected error to be of type *MagicMismatch got '%v'", err)
+		}
+
+		expected := "Invalid magic bytes, received '3c68746d6c3e3c62' expected '89504e470d0a1a0a'"
+
+		if err.Error() != expected {
+			t.Fatalf("Expected '%s' got '%s'", expected, err.Error())
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+
+	content := append([]byte("VERSION 2\n"), bytes.Repeat([]byte("0123456789"), 100)...)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "versioned.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/versioned.txt"
+
+	verifier := func(version string) VerifyFn {
+		return func(r io.ReadSeeker) error {
+
+			b := make([]byte, 10)
+
+			if _, err := io.ReadFull(r, b); err != nil {
+				return err
+			}
+
+			if string(b) != "VERSION "+version+"\n" {
+				return fmt.Errorf("unsupported version '%s'", bytes.TrimSpace(b))
+			}
+
+			return nil
+		}
+	}
+
+	for _, minSize := range []int64{0, -1} {
+
+		f, err := Open(url, &Options{MinSizeForRanges: minSize, Verify: verifier("2")})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the verification doesn't affect reading
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		f, err = Open(url, &Options{MinSizeForRanges: minSize, Verify: verifier("1")})
+		if err == nil {
+			f.Close()
+			t.Fatal("Expected error got <nil>")
+		}
+
+		expected := "unsupported version 'VERSION 2'"
+		if err.Error() != expected {
+			t.Fatalf("Expected '%s' got '%s'", expected, err)
+		}
+	}
+}
+
+func TestValidateJSON(t *testing.T) {
+
+	valid := []byte(`{"items": [` + strings.Repeat(`{"id": 1, "name": "item"}, `, 50) + `{"id": 2}]}`)
+
+	tests := []struct {
+		name     string
+		content  []byte
+		expected string
+	}{
+		{name: "valid.json", content: valid},
+		{name: "truncated.json", content: valid[:len(valid)-20], expected: "Invalid JSON content, unexpected end of content"},
+		{name: "error.json", content: []byte("<html><body>Internal Server Error</body></html>"), expected: "Invalid JSON content, invalid character '<' looking for beginning of value"},
+		{name: "multiple.json", content: []byte(`{"id": 1} {"id": 2}`), expected: "Invalid JSON content, expected a single value got '2'"},
+		{name: "empty.json", content: []byte(" "), expected: "Invalid JSON content, expected a single value got '0'"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, tt := range tests {
+			if r.URL.Path == "/testdata/"+tt.name {
+				http.ServeContent(w, r, tt.name, time.Time{}, bytes.NewReader(tt.content))
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	for _, minSize := range []int64{0, -1} {
+		for _, tt := range tests {
+
+			f, err := Open(server.URL+"/testdata/"+tt.name, &Options{
+				MinSizeForRanges: minSize,
+				ValidateJSON:     true,
+				Concurrency: func(size int64) int {
+					return 2
+				},
+			})
+
+			if tt.expected == "" {
+				if err != nil {
+					t.Fatalf("%s: %s", tt.name, err)
+				}
+
+				// the validation doesn't affect reading
+				b, err := ioutil.ReadAll(f)
+				f.Close()
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(b, tt.content) {
+					t.Fatalf("%s: Downloaded content does not match", tt.name)
+				}
+
+				continue
+			}
+
+			if _, ok := err.(*InvalidContent); !ok {
+				t.Fatalf("%s: Expected error to be of type *InvalidContent got '%v'", tt.name, err)
+			}
+
+			if err.Error() != tt.expected {
+				t.Fatalf("%s: Expected '%s' got '%s'", tt.name, tt.expected, err)
+			}
+		}
+	}
+}
+
+func TestAccept(t *testing.T) {
+
+	content := append([]byte("VERSION 1\n"), bytes.Repeat([]byte("0123456789"), 100)...)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "accept.txt", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	tempDir, err := ioutil.TempDir("", "go-download-accept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	url := server.URL + "/testdata/accept.txt"
+
+	accept := func(minVersion string) AcceptFn {
+		return func(info os.FileInfo, r io.ReadSeeker) (bool, error) {
+
+			if info.Name() != "accept.txt" || info.Size() != int64(len(content)) {
+				return false, fmt.Errorf("unexpected file '%s' of size '%d'", info.Name(), info.Size())
+			}
+
+			b := make([]byte, 10)
+
+			if _, err := io.ReadFull(r, b); err != nil {
+				return false, err
+			}
+
+			return string(b) >= "VERSION "+minVersion+"\n", nil
+		}
+	}
+
+	for _, minSize := range []int64{0, -1} {
+
+		options := &Options{TempDir: tempDir, MinSizeForRanges: minSize, Accept: accept("1")}
+
+		f, err := Open(url, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the decision doesn't affect reading
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(b, content) {
+			t.Fatal("Downloaded content does not match")
+		}
+
+		options.Accept = accept("2")
+
+		_, err = Open(url, options)
+		if _, ok := err.(*Rejected); !ok {
+			t.Fatalf("Expected error to be of type *Rejected got '%v'", err)
+		}
+
+		expected := "Download rejected for '" + url + "'"
+		if err.Error() != expected {
+			t.Fatalf("Expected '%s' got '%s'", expected, err)
+		}
+
+		infos, err := ioutil.ReadDir(tempDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(infos) != 0 {
+			t.Fatalf("Expected the rejected download to be removed got '%d' files", len(infos))
+		}
+	}
+}
+
+func TestVerifyHeadFailed(t *testing.T) {
+
+	content := []byte(`{"a":1}`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		http.ServeContent(w, r, "nohead.json", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	var verified, accepted []byte
+
+	f, err := Open(server.URL+"/nohead.json", &Options{
+		ValidateJSON: true,
+		Verify: func(r io.ReadSeeker) (err error) {
+			verified, err = ioutil.ReadAll(r)
+			return
+		},
+		Accept: func(info os.FileInfo, r io.ReadSeeker) (bool, error) {
+			b, err := ioutil.ReadAll(r)
+			accepted = b
+			return err == nil, err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if !bytes.Equal(verified, content) {
+		t.Fatalf("Expected Verify to read '%s' got '%s'", content, verified)
+	}
+
+	if !bytes.Equal(accepted, content) {
+		t.Fatalf("Expected Accept to read '%s' got '%s'", content, accepted)
+	}
+}